@@ -0,0 +1,47 @@
+package shaft
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+
+	"github.com/aegistudio/shaft/core"
+)
+
+// WithSignalContext cancels the Context of the run once one
+// of the specified signals is received, so that the remaining
+// nodes are not executed, and the providers and invokes might
+// observe it and stop gracefully. It is also supplied as a
+// context.Context. The signals are no longer captured after
+// the execution plan has been completed.
+func WithSignalContext(signals ...os.Signal) Option {
+	return Module(
+		core.WithContext(func(
+			ctx context.Context,
+		) (context.Context, context.CancelFunc) {
+			return signal.NotifyContext(ctx, signals...)
+		}),
+		convertProvider(func(ctx Context) context.Context {
+			return ctx
+		}).builtin().option(opProvide),
+	)
+}
+
+// Context is the context of the Run, which is supplied by Run
+// and derived from the context passed to RunContext.
+//
+// The context is canceled as soon as any node returns an
+// error, so the nodes in flight, e.g. the goroutines started
+// by the stacked functions, can abort before the error is
+// propagated back to them.
+type Context context.Context
+
+func supplyContext() Option {
+	typ := reflect.TypeOf((*Context)(nil)).Elem()
+	return core.SupplyContext(func(ctx context.Context) reflect.Value {
+		return reflect.ValueOf(Context(ctx))
+	}, convertSingle(typ), valuesOp{
+		op: opSupply, types: []reflect.Type{typ},
+	})
+}
@@ -0,0 +1,73 @@
+package shaft_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+func TestWithSignalContext(t *testing.T) {
+	assert := assert.New(t)
+
+	// The signal context is derived from the run context, and
+	// the remaining nodes are not executed once it is done.
+	parent, cancel := context.WithCancel(context.Background())
+	invoked := false
+	err := shaft.RunContext(parent,
+		shaft.WithSignalContext(os.Interrupt),
+		shaft.Invoke(func(ctx context.Context) {
+			assert.NoError(ctx.Err())
+			cancel()
+			<-ctx.Done()
+		}),
+		shaft.Invoke(func() {
+			invoked = true
+		}),
+	)
+	assert.ErrorIs(err, context.Canceled)
+	assert.False(invoked)
+
+	var ctx context.Context
+	assert.NoError(shaft.Run(
+		shaft.WithSignalContext(os.Interrupt),
+		shaft.Populate(&ctx),
+	))
+	assert.ErrorIs(ctx.Err(), context.Canceled)
+}
+
+func TestContextCanceledOnError(t *testing.T) {
+	assert := assert.New(t)
+
+	errFailed := errors.New("failed")
+	var observed error
+	err := shaft.Run(
+		shaft.Stack(func(f func(*chainA) error, ctx shaft.Context) error {
+			done := make(chan error, 1)
+			go func() {
+				<-ctx.Done()
+				done <- ctx.Err()
+			}()
+			err := f(&chainA{})
+			select {
+			case observed = <-done:
+			case <-time.After(time.Second):
+			}
+			return err
+		}),
+		shaft.Invoke(func(*chainA) error {
+			return errFailed
+		}),
+	)
+	assert.ErrorIs(err, errFailed)
+	assert.ErrorIs(observed, context.Canceled)
+
+	var ctx shaft.Context
+	assert.NoError(shaft.Run(shaft.Populate(&ctx)))
+	assert.ErrorIs(ctx.Err(), context.Canceled)
+}
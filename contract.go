@@ -0,0 +1,116 @@
+package shaft
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aegistudio/shaft/core"
+)
+
+// contractType is a type in the contract, which is identified
+// by its name instead of its value.
+type contractType struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+func (t contractType) String() string {
+	if t.Name == "" {
+		return t.Type
+	}
+	return fmt.Sprintf("%s(name=%s)", t.Type, t.Name)
+}
+
+// contract is the serialized form of the contract of module.
+type contract struct {
+	Provides []contractType `json:"provides"`
+	Consumes []contractType `json:"consumes"`
+}
+
+func convertContractTypes(specs []core.Spec) []contractType {
+	result := []contractType{}
+	for _, spec := range specs {
+		result = append(result, contractType{
+			Type: spec.Type.String(), Name: spec.Name,
+		})
+	}
+	return result
+}
+
+func moduleContract(opts ...Option) (contract, error) {
+	provides, err := core.Provided(opts...)
+	if err != nil {
+		return contract{}, err
+	}
+	// The types supplied by Run are never consumed from the
+	// outside, but they are not provided by the module either.
+	consumes, err := core.Unresolved(inspected(opts))
+	if err != nil {
+		return contract{}, err
+	}
+	return contract{
+		Provides: convertContractTypes(provides),
+		Consumes: convertContractTypes(consumes),
+	}, nil
+}
+
+// Contract serializes the contract of the module, which is
+// the types it provides and the types it consumes but does not
+// provide, see also Provided and Unresolved. The types are
+// identified by their names, so the contract can be checked by
+// VerifyContract against a module from another compilation
+// unit, e.g. a plugin, before linking them.
+func Contract(opts ...Option) ([]byte, error) {
+	c, err := moduleContract(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(c)
+}
+
+// VerifyContract verifies the module satisfies the contract
+// serialized by Contract, that is, the module provides every
+// type declared as provided, and consumes no type other than
+// those declared as consumed from outside.
+func VerifyContract(data []byte, opts ...Option) error {
+	var declared contract
+	if err := json.Unmarshal(data, &declared); err != nil {
+		return err
+	}
+	actual, err := moduleContract(opts...)
+	if err != nil {
+		return err
+	}
+	var problems []string
+	if missing := contractDiff(
+		declared.Provides, actual.Provides); len(missing) > 0 {
+		problems = append(problems, fmt.Sprintf(
+			"module does not provide %s", strings.Join(missing, ", ")))
+	}
+	if extra := contractDiff(
+		actual.Consumes, declared.Consumes); len(extra) > 0 {
+		problems = append(problems, fmt.Sprintf(
+			"module consumes undeclared %s", strings.Join(extra, ", ")))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("contract violated: %s",
+			strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// contractDiff returns the types in a but not in b.
+func contractDiff(a, b []contractType) []string {
+	set := make(map[contractType]struct{})
+	for _, item := range b {
+		set[item] = struct{}{}
+	}
+	var result []string
+	for _, item := range a {
+		if _, ok := set[item]; !ok {
+			result = append(result, item.String())
+		}
+	}
+	return result
+}
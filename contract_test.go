@@ -0,0 +1,48 @@
+package shaft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+func TestContract(t *testing.T) {
+	assert := assert.New(t)
+
+	data, err := shaft.Contract(
+		shaft.Provide(provideChainB),
+		shaft.Provide(provideChainC),
+		shaft.Named("replica", func(*chainA) *database {
+			return &database{}
+		}),
+		shaft.Invoke(func(shaft.Context, *shaft.Lifecycle) {}),
+	)
+	assert.NoError(err)
+	assert.JSONEq(`{
+		"provides": [
+			{"type": "*shaft_test.chainB"},
+			{"type": "*shaft_test.chainC"},
+			{"type": "*shaft_test.database", "name": "replica"}
+		],
+		"consumes": [{"type": "*shaft_test.chainA"}]
+	}`, string(data))
+
+	assert.NoError(shaft.VerifyContract(data,
+		shaft.Provide(func(*chainA) (*chainB, *chainC) {
+			return &chainB{}, &chainC{}
+		}),
+		shaft.Named("replica", func() *database {
+			return &database{}
+		}),
+		shaft.Provide(provideHandlerX),
+	))
+	assert.EqualError(shaft.VerifyContract(data,
+		shaft.Provide(provideChainB),
+		shaft.Provide(provideChainC),
+		shaft.Provide(func(*config) *chainA { return &chainA{} }),
+	), "contract violated: module does not provide "+
+		"*shaft_test.database(name=replica); "+
+		"module consumes undeclared *shaft_test.config")
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"reflect"
+)
+
+// Container is a long-lived container of the options, which
+// can be added incrementally, and run with different invokes
+// repeatedly without registering the options again.
+//
+// The graph built from the added options is cached until new
+// options are added, and so is the execution plan of the
+// consumers added to the container.
+type Container struct {
+	opts    []Option
+	option  *option
+	program *Program
+}
+
+// NewContainer creates a container with the options.
+func NewContainer(opts ...Option) *Container {
+	return &Container{opts: opts}
+}
+
+// Add the options into the container, which invalidates the
+// cached graph and execution plan.
+func (c *Container) Add(opts ...Option) {
+	c.opts = append(c.opts, opts...)
+	c.option = nil
+	c.program = nil
+}
+
+// Run the consumers added to the container together with the
+// invokes, which are only effective in this run.
+func (c *Container) Run(invokes ...Option) error {
+	return c.RunContext(context.Background(), invokes...)
+}
+
+// RunContext is like Run, but stops executing the remaining
+// nodes once the context is done, see also RunContext.
+func (c *Container) RunContext(ctx context.Context, invokes ...Option) error {
+	if c.option == nil {
+		option, err := apply(c.opts...)
+		if err != nil {
+			return err
+		}
+		c.option = option
+	}
+	if len(invokes) == 0 {
+		if c.program == nil {
+			program, err := c.option.compile()
+			if err != nil {
+				return err
+			}
+			c.program = program
+		}
+		return c.program.RunContext(ctx)
+	}
+	option := c.option.clone()
+	Module(invokes...)(option)
+	if err := option.validate(); err != nil {
+		return err
+	}
+	program, err := option.compile()
+	if err != nil {
+		return err
+	}
+	return program.RunContext(ctx)
+}
+
+// compile the applied options into a program.
+func (o *option) compile() (*Program, error) {
+	tp := newGraphToposort()
+	nodes, err := o.g.toposortWith(tp, o.consumers)
+	if err != nil {
+		return nil, err
+	}
+	if o.unusedReporter != nil {
+		o.unusedReporter(o.g.unused(nodes))
+	}
+	return &Program{
+		nodes: nodes,
+		info:  newPlanInfo(nodes),
+		resolve: func(spec Spec) (reflect.Value, error) {
+			return o.g.resolve(tp, extractGraphKey(spec))
+		},
+		concurrency: o.concurrency,
+		observer:    o.observer,
+		stats:       o.stats,
+		contexts:    o.contexts,
+	}, nil
+}
+
+// clone the applied options so that more options can be
+// applied without affecting the original one.
+func (o *option) clone() *option {
+	result := *o
+	result.g = o.g.clone()
+	result.consumers = append([]graphNode(nil), o.consumers...)
+	result.contexts = append([]func(
+		context.Context) (context.Context, context.CancelFunc)(nil),
+		o.contexts...)
+	result.once = make(map[string]struct{})
+	for key := range o.once {
+		result.once[key] = struct{}{}
+	}
+	return &result
+}
+
+func cloneSlots(
+	m map[graphNodeKey][]graphNodeOutputSlot,
+) map[graphNodeKey][]graphNodeOutputSlot {
+	result := make(map[graphNodeKey][]graphNodeOutputSlot)
+	for key, slots := range m {
+		result[key] = append([]graphNodeOutputSlot(nil), slots...)
+	}
+	return result
+}
+
+func (g *graph) clone() *graph {
+	result := *g
+	result.nodes = append([]graphNode(nil), g.nodes...)
+	result.provide = cloneSlots(g.provide)
+	result.decorate = cloneSlots(g.decorate)
+	result.replace = make(map[graphNodeKey]struct{})
+	for key := range g.replace {
+		result.replace[key] = struct{}{}
+	}
+	result.exclusive = append([][2]reflect.Type(nil), g.exclusive...)
+	return &result
+}
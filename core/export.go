@@ -0,0 +1,140 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// exportEdge is an edge of the exported graph, which is from
+// the node providing or decorating the key to the target.
+type exportEdge struct {
+	from     int
+	to       string
+	key      graphNodeKey
+	decorate bool
+}
+
+func sortedSlots(slots []graphNodeOutputSlot) []graphNodeOutputSlot {
+	slots = append([]graphNodeOutputSlot(nil), slots...)
+	sort.Slice(slots, func(i, j int) bool {
+		return slots[i].id < slots[j].id
+	})
+	return slots
+}
+
+// exportEdges walks through the graph structure to collect
+// the edges, without executing any of the nodes. The edges
+// are from the providers to the nodes consuming their outputs,
+// and from the decorators to the nodes consuming the decorated
+// outputs. The targets are named n<id> for the graph nodes
+// and c<id> for the consumers.
+func (o *option) exportEdges() []exportEdge {
+	var result []exportEdge
+	edges := func(to string, node graphNode) {
+		for _, input := range node.input {
+			key := extractGraphKey(input)
+			for _, slot := range sortedSlots(o.g.providers(key)) {
+				result = append(result, exportEdge{
+					from: slot.id, to: to, key: key,
+				})
+			}
+			if input.Decorate {
+				continue
+			}
+			for _, slot := range sortedSlots(o.g.decorate[key]) {
+				result = append(result, exportEdge{
+					from: slot.id, to: to, key: key, decorate: true,
+				})
+			}
+		}
+	}
+	for id, node := range o.g.nodes {
+		edges(fmt.Sprintf("n%d", id), node)
+	}
+	for id, node := range o.consumers {
+		edges(fmt.Sprintf("c%d", id), node)
+	}
+	return result
+}
+
+// mermaidQuote quotes the text as a mermaid string.
+func mermaidQuote(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, "#quot;") + `"`
+}
+
+// Mermaid renders the graph of the nodes in the mermaid
+// flowchart syntax, which can be embedded in the markdown.
+//
+// The nodes are labeled by their display names, and the
+// consumers are rounded. The edges are from the providers
+// to the nodes consuming their outputs, and those of groups
+// are thick. The edges from the decorators to the nodes
+// consuming the decorated outputs are dotted.
+func Mermaid(opts ...Option) (string, error) {
+	option, err := apply(opts...)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for id, node := range option.g.nodes {
+		fmt.Fprintf(&b, "    n%d[%s]\n", id,
+			mermaidQuote(node.String(id)))
+	}
+	for id, node := range option.consumers {
+		fmt.Fprintf(&b, "    c%d([%s])\n", id,
+			mermaidQuote(node.displayName()))
+	}
+	for _, edge := range option.exportEdges() {
+		arrow := "-->"
+		if edge.decorate {
+			arrow = "-.->"
+		} else if edge.key.group {
+			arrow = "==>"
+		}
+		fmt.Fprintf(&b, "    n%d %s|%s| %s\n", edge.from, arrow,
+			mermaidQuote(edge.key.String()), edge.to)
+	}
+	return b.String(), nil
+}
+
+// ExportDOT writes the graph of the nodes as a graphviz DOT
+// document, without executing any of the nodes.
+//
+// The nodes are labeled by their display names, and the
+// consumers are rounded boxes. The edges are from the
+// providers to the nodes consuming their outputs, and those
+// of groups are bold. The edges from the decorators to the
+// nodes consuming the decorated outputs are dashed.
+func ExportDOT(w io.Writer, opts ...Option) error {
+	option, err := apply(opts...)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	b.WriteString("digraph shaft {\n")
+	for id, node := range option.g.nodes {
+		fmt.Fprintf(&b, "    n%d [label=%s];\n", id,
+			strconv.Quote(node.String(id)))
+	}
+	for id, node := range option.consumers {
+		fmt.Fprintf(&b, "    c%d [label=%s, shape=box, style=rounded];\n",
+			id, strconv.Quote(node.displayName()))
+	}
+	for _, edge := range option.exportEdges() {
+		style := ""
+		if edge.decorate {
+			style = ", style=dashed"
+		} else if edge.key.group {
+			style = ", style=bold"
+		}
+		fmt.Fprintf(&b, "    n%d -> %s [label=%s%s];\n", edge.from,
+			edge.to, strconv.Quote(edge.key.String()), style)
+	}
+	b.WriteString("}\n")
+	_, err = io.WriteString(w, b.String())
+	return err
+}
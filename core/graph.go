@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"reflect"
+	"sort"
 )
 
 // graphNodeKey represents the input and output of a node.
@@ -57,6 +58,35 @@ type graphNode struct {
 	// If this value is not present, we will use index of
 	// the graph node instead.
 	format fmt.Stringer
+
+	// supply indicates the node supplies values directly
+	// instead of constructing them.
+	supply bool
+
+	// explicit indicates the decorate ports of the node are
+	// declared explicitly instead of being inferred.
+	explicit bool
+
+	// fallback indicates the node provides the default of the
+	// single types, which is used only when no other node is
+	// providing them.
+	fallback bool
+
+	// final indicates the consumer is executed after all the
+	// other nodes in the execution plan.
+	final bool
+
+	// infallible indicates the node is constructed by a
+	// function which never returns an error.
+	infallible bool
+
+	// override indicates the single types provided by the node
+	// shadow those provided by the other nodes, see Replace.
+	override bool
+
+	// builtin indicates the node is inserted by the framework
+	// instead of the user, see also Builtin.
+	builtin bool
 }
 
 func (g graphNode) String(id int) string {
@@ -73,15 +103,49 @@ func (g graphNode) String(id int) string {
 // execution plan, and only then will we know if there
 // were cyclic dependencies there.
 type graph struct {
+	// semaphore bounds the providers executed at the same
+	// time, which might be nil, see also MaxConcurrency.
+	semaphore chan struct{}
+
 	nodes    []graphNode
 	provide  map[graphNodeKey][]graphNodeOutputSlot
 	decorate map[graphNodeKey][]graphNodeOutputSlot
+
+	// assignable indicates a single type can be provided by
+	// the nodes providing types assignable to it, when there
+	// is no node providing the type exactly.
+	assignable bool
+
+	// warnings is the sink of warnings emitted while building
+	// the execution plan, which might be nil.
+	warnings *Warnings
+
+	// noImplicitDecorate indicates the decorate ports must be
+	// declared explicitly.
+	noImplicitDecorate bool
+
+	// noSupplyShadow indicates a type must not be supplied
+	// and provided at the same time.
+	noSupplyShadow bool
+
+	// requireErrorReturns indicates the nodes must not be
+	// constructed by the functions never returning an error.
+	requireErrorReturns bool
+
+	// replace is the set of groups which keep only the last
+	// provided member.
+	replace map[graphNodeKey]struct{}
+
+	// exclusive are the pairs of the member types which must
+	// not be collected into the same group.
+	exclusive [][2]reflect.Type
 }
 
 func newGraph() *graph {
 	return &graph{
 		provide:  make(map[graphNodeKey][]graphNodeOutputSlot),
 		decorate: make(map[graphNodeKey][]graphNodeOutputSlot),
+		replace:  make(map[graphNodeKey]struct{}),
 	}
 }
 
@@ -93,10 +157,24 @@ func (g *graph) insert(node graphNode) {
 	for index, item := range node.output {
 		key := extractGraphKey(item)
 		if item.Decorate {
-			g.decorate[key] = append(g.decorate[key], graphNodeOutputSlot{
+			// Keep the decorators sorted by their order, so
+			// that they can be applied one by one later.
+			slots := g.decorate[key]
+			pos := len(slots)
+			for pos > 0 {
+				prev := slots[pos-1]
+				if g.nodes[prev.id].output[prev.index].Order <= item.Order {
+					break
+				}
+				pos--
+			}
+			slots = append(slots, graphNodeOutputSlot{})
+			copy(slots[pos+1:], slots[pos:])
+			slots[pos] = graphNodeOutputSlot{
 				id:    id,
 				index: index,
-			})
+			}
+			g.decorate[key] = slots
 		} else {
 			g.provide[key] = append(g.provide[key], graphNodeOutputSlot{
 				id:    id,
@@ -106,6 +184,165 @@ func (g *graph) insert(node graphNode) {
 	}
 }
 
+// checkSupplyShadow checks whether a type is supplied and
+// provided at the same time, when it is required.
+func (g *graph) checkSupplyShadow() error {
+	if !g.noSupplyShadow {
+		return nil
+	}
+	for id, node := range g.nodes {
+		if !node.supply {
+			continue
+		}
+		for _, item := range node.output {
+			if item.Group || item.Decorate {
+				continue
+			}
+			key := extractGraphKey(item)
+			for _, slot := range g.provide[key] {
+				if other := g.nodes[slot.id]; !other.supply &&
+					!other.fallback && !other.override {
+					return fmt.Errorf(
+						"type %s supplied by node %q is also provided by node %q",
+						key, node.String(id), other.String(slot.id))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkOverride reports the first single type provided by an
+// overriding node, but not by any other node, which is likely
+// to be a typo since there's nothing to be replaced.
+func (g *graph) checkOverride() error {
+	for id, node := range g.nodes {
+		if !node.override {
+			continue
+		}
+		for _, item := range node.output {
+			if item.Group || item.Decorate {
+				continue
+			}
+			key := extractGraphKey(item)
+			replaced := false
+			for _, slot := range g.provide[key] {
+				if !g.nodes[slot.id].override {
+					replaced = true
+					break
+				}
+			}
+			if !replaced {
+				return fmt.Errorf(
+					"type %s replaced by node %q is not provided by other nodes",
+					key, node.String(id))
+			}
+		}
+	}
+	return nil
+}
+
+// checkDecorate reports the first decorator decorating a
+// single type which is not provided by any other node.
+func (g *graph) checkDecorate() error {
+	for id, node := range g.nodes {
+		for _, item := range node.output {
+			if !item.Decorate || item.Group {
+				continue
+			}
+			key := extractGraphKey(item)
+			if len(g.providers(key)) == 0 {
+				return g.decorateError(id, key)
+			}
+		}
+	}
+	return nil
+}
+
+// decorateError generates the error of the decorator whose
+// decorated type is not provided.
+func (g *graph) decorateError(id int, key graphNodeKey) *ErrDecorate {
+	node := g.nodes[id]
+	var provides []string
+	for _, item := range node.output {
+		if !item.Decorate {
+			provides = append(provides, extractGraphKey(item).String())
+		}
+	}
+	return &ErrDecorate{
+		Node:     node.String(id),
+		Type:     key.String(),
+		Provides: provides,
+	}
+}
+
+// checkImplicitDecorate reports the first node decorating
+// types without declaring them explicitly.
+func (g *graph) checkImplicitDecorate() error {
+	if !g.noImplicitDecorate {
+		return nil
+	}
+	for id, node := range g.nodes {
+		if node.explicit {
+			continue
+		}
+		for _, item := range node.output {
+			if item.Decorate {
+				return fmt.Errorf(
+					"node %q implicitly decorates type %s",
+					node.String(id), extractGraphKey(item))
+			}
+		}
+	}
+	return nil
+}
+
+// checkErrorReturns reports the first node constructed by
+// the function never returning an error.
+func (g *graph) checkErrorReturns() error {
+	if !g.requireErrorReturns {
+		return nil
+	}
+	for id, node := range g.nodes {
+		if node.infallible {
+			return fmt.Errorf(
+				"node %q does not return an error", node.String(id))
+		}
+	}
+	return nil
+}
+
+// checkSupplyConflict reports the first type supplied by
+// more than one supply node, which is always ambiguous even
+// if it has not been consumed yet.
+func (g *graph) checkSupplyConflict() error {
+	for _, node := range g.nodes {
+		if !node.supply {
+			continue
+		}
+		for _, item := range node.output {
+			if item.Group || item.Decorate {
+				continue
+			}
+			key := extractGraphKey(item)
+			var nodes []string
+			for _, slot := range g.provide[key] {
+				if other := g.nodes[slot.id]; other.supply &&
+					!other.fallback && !other.override {
+					nodes = append(nodes, other.String(slot.id))
+				}
+			}
+			if len(nodes) > 1 {
+				return &ErrConflict{
+					Nodes: nodes,
+					Type:  key.String(),
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // executionParam is the parameters or results for the
 // execution of a series of execution node.
 type executionParam struct {
@@ -130,16 +367,27 @@ func (c *executionCollect) collect() reflect.Value {
 // handle the execute manually since the container knows
 // nothing about how to handle the value.
 type executionNode interface {
-	execute()
+	execute() error
 }
 
 type graphUserNode struct {
 	params *executionParam
 	result *executionParam
 	value  interface{}
+
+	// id is the id of the graph node that generates this
+	// node, or -1 if it is generated by a consumer.
+	id   int
+	node graphNode
+
+	// position is the position of the node in the execution
+	// plan, and scoped are the indices of the inputs which
+	// might be specialized for it, see also NodeScoped.
+	position int
+	scoped   []int
 }
 
-func (graphUserNode) execute() {
+func (graphUserNode) execute() error {
 	panic("graphUserNode.execute must not be invoked")
 }
 
@@ -148,22 +396,62 @@ type collectParamNode struct {
 	result *executionParam
 }
 
-func (c collectParamNode) execute() {
+func (c collectParamNode) execute() error {
 	for i, item := range c.items {
 		c.result.params[i] = item.collect()
 	}
+	return nil
 }
 
 type collectGroupNode struct {
-	items  []executionCollect
-	result *executionParam
+	typ     reflect.Type
+	items   []executionCollect
+	result  *executionParam
+	replace bool
+
+	// names are the display names of the nodes providing
+	// the items, and exclusive are the pairs of the member
+	// types which must not be collected together.
+	names     []string
+	exclusive [][2]reflect.Type
 }
 
-func (c collectGroupNode) execute() {
-	for _, item := range c.items {
-		c.result.params[0] = reflect.AppendSlice(
-			c.result.params[0], item.collect())
+func (c collectGroupNode) execute() error {
+	// XXX: the group must be collected from scratch, since
+	// the execution plan might be executed more than once.
+	result := reflect.MakeSlice(c.typ, 0, 0)
+	members := make(map[reflect.Type]int)
+	for i, item := range c.items {
+		value := item.collect()
+		for j := 0; j < value.Len(); j++ {
+			member := value.Index(j)
+			if member.Kind() == reflect.Interface {
+				member = member.Elem()
+			}
+			if !member.IsValid() {
+				continue
+			}
+			if _, ok := members[member.Type()]; !ok {
+				members[member.Type()] = i
+			}
+		}
+		result = reflect.AppendSlice(result, value)
+	}
+	for _, pair := range c.exclusive {
+		a, okA := members[pair[0]]
+		b, okB := members[pair[1]]
+		if okA && okB {
+			return &ErrConflict{
+				Nodes: []string{c.names[a], c.names[b]},
+				Type:  c.typ.String(),
+			}
+		}
+	}
+	if c.replace && result.Len() > 1 {
+		result = result.Slice(result.Len()-1, result.Len())
 	}
+	c.result.params[0] = result
+	return nil
 }
 
 // graphToposort keeps track of the instantiated graph nodes,
@@ -175,6 +463,10 @@ type graphToposort struct {
 	decorating map[graphNodeKey]executionCollect
 	pending    map[int]struct{}
 	result     []executionNode
+
+	// path is the ids of the pending nodes in the order of
+	// traversal, for reporting the cyclic dependency.
+	path []int
 }
 
 func newGraphToposort() *graphToposort {
@@ -201,8 +493,12 @@ func (g *graph) toposortGenerateGraphNodeID(
 		return params, nil
 	}
 	tp.pending[id] = struct{}{}
-	defer delete(tp.pending, id)
-	params, err := g.toposortGenerateGraphNode(tp, g.nodes[id])
+	tp.path = append(tp.path, id)
+	defer func() {
+		delete(tp.pending, id)
+		tp.path = tp.path[:len(tp.path)-1]
+	}()
+	params, err := g.toposortGenerateGraphNode(tp, id, g.nodes[id])
 	if err != nil {
 		return nil, &ErrDependency{
 			Node: g.nodes[id].String(id),
@@ -213,13 +509,85 @@ func (g *graph) toposortGenerateGraphNodeID(
 	return params, nil
 }
 
+// providers returns the output slots providing the single
+// type, falling back to the assignable ones if enabled.
+func (g *graph) providers(item graphNodeKey) []graphNodeOutputSlot {
+	outputSlots := g.provide[item]
+	if item.group {
+		return outputSlots
+	}
+	if len(outputSlots) > 0 || !g.assignable {
+		return g.overrideFallback(outputSlots)
+	}
+	for key, slots := range g.provide {
+		if key.group || key.name != item.name {
+			continue
+		}
+		if key.typ.AssignableTo(item.typ) {
+			outputSlots = append(outputSlots, slots...)
+		}
+	}
+	return g.overrideFallback(outputSlots)
+}
+
+// missingOptional returns whether the input is an optional
+// single type which is not provided by any node.
+func (g *graph) missingOptional(input Spec) bool {
+	if !input.Optional || input.Group || input.Decorate {
+		return false
+	}
+	return len(g.providers(extractGraphKey(input))) == 0
+}
+
+// overrideFallback removes the slots of the fallback nodes if
+// there's any slot of the other nodes, and keeps only the slots
+// of the overriding nodes if there's any.
+func (g *graph) overrideFallback(
+	slots []graphNodeOutputSlot,
+) []graphNodeOutputSlot {
+	var result, override []graphNodeOutputSlot
+	for _, slot := range slots {
+		node := g.nodes[slot.id]
+		if node.override {
+			override = append(override, slot)
+		}
+		if !node.fallback {
+			result = append(result, slot)
+		}
+	}
+	if len(override) > 0 {
+		return override
+	}
+	if len(result) == 0 {
+		return slots
+	}
+	return result
+}
+
+// toposortCycle generates the error of the cyclic dependency
+// on the pending node, by tracing back the traversal path.
+func (g *graph) toposortCycle(tp *graphToposort, id int) error {
+	begin := len(tp.path) - 1
+	for begin > 0 && tp.path[begin] != id {
+		begin--
+	}
+	var nodes []string
+	for _, item := range append(tp.path[begin:], id) {
+		nodes = append(nodes, g.nodes[item].String(item))
+	}
+	return &ErrCycle{Nodes: nodes}
+}
+
 // toposortGenerateSingle generates the single collect
 // corresponding to a node.
 func (g *graph) toposortGenerateSingle(
 	tp *graphToposort, item graphNodeKey,
 ) (executionCollect, error) {
-	outputSlots := g.provide[item]
+	outputSlots := g.providers(item)
 	if len(outputSlots) == 0 {
+		if slots := g.decorate[item]; len(slots) > 0 {
+			return executionCollect{}, g.decorateError(slots[0].id, item)
+		}
 		return executionCollect{}, fmt.Errorf(
 			"type %s missing dependency", item)
 	}
@@ -230,9 +598,7 @@ func (g *graph) toposortGenerateSingle(
 	outputSlot := outputSlots[0]
 	id := outputSlot.id
 	if _, ok := tp.pending[id]; ok {
-		return executionCollect{}, fmt.Errorf(
-			"type %s cyclic dependency on node %s",
-			g.nodes[id].String(id), item)
+		return executionCollect{}, g.toposortCycle(tp, id)
 	}
 	params, err := g.toposortGenerateGraphNodeID(tp, id)
 	if err != nil {
@@ -244,6 +610,11 @@ func (g *graph) toposortGenerateSingle(
 	}, nil
 }
 
+// order returns the order of the output slot.
+func (g *graph) order(slot graphNodeOutputSlot) int {
+	return g.nodes[slot.id].output[slot.index].Order
+}
+
 // toposortGenerateGrouped generates the group collect
 // node and returns the execution param of that group.
 func (g *graph) toposortGenerateGrouped(
@@ -260,10 +631,17 @@ func (g *graph) toposortGenerateGrouped(
 			reflect.MakeSlice(group.typ, 0, 0),
 		},
 	}
+	_, replace := g.replace[group]
 	node := &collectGroupNode{
-		result: result,
-	}
-	outputSlots := g.provide[group]
+		typ:       group.typ,
+		result:    result,
+		replace:   replace,
+		exclusive: g.exclusive,
+	}
+	outputSlots := append([]graphNodeOutputSlot(nil), g.provide[group]...)
+	sort.SliceStable(outputSlots, func(i, j int) bool {
+		return g.order(outputSlots[i]) < g.order(outputSlots[j])
+	})
 	for _, outputSlot := range outputSlots {
 		params, err := g.toposortGenerateGraphNodeID(tp, outputSlot.id)
 		if err != nil {
@@ -273,6 +651,8 @@ func (g *graph) toposortGenerateGrouped(
 			result: params,
 			index:  outputSlot.index,
 		})
+		node.names = append(node.names,
+			g.nodes[outputSlot.id].String(outputSlot.id))
 	}
 	tp.result = append(tp.result, node)
 	tp.grouped[group] = result
@@ -323,6 +703,29 @@ func (g *graph) toposortGenerateBaseCollect(
 	return baseCollect, nil
 }
 
+// toposortBaseError attributes the error generating the base
+// collect of a decorated spec to the first decorator, since
+// the base is generated ahead of the decorators, but it is
+// consumed by the first decorator actually. So the path of
+// the dependency error passes through the decorator.
+func (g *graph) toposortBaseError(
+	tp *graphToposort, spec Spec, err error,
+) error {
+	key := extractGraphKey(spec)
+	slots := g.decorate[key]
+	if len(slots) == 0 || spec.Decorate {
+		return err
+	}
+	if _, ok := tp.decorated[key]; ok {
+		return err
+	}
+	id := slots[0].id
+	return &ErrDependency{
+		Node: g.nodes[id].String(id),
+		Err:  err,
+	}
+}
+
 // toposortGenerateCollect creates the general collect for
 // executing a graph node's parameter.
 func (g *graph) toposortGenerateCollect(
@@ -331,7 +734,7 @@ func (g *graph) toposortGenerateCollect(
 	key := extractGraphKey(spec)
 	baseCollect, err := g.toposortGenerateBaseCollect(tp, key)
 	if err != nil {
-		return executionCollect{}, nil
+		return executionCollect{}, g.toposortBaseError(tp, spec, err)
 	}
 
 	// Check whether we are in the middle way of initializing
@@ -362,6 +765,15 @@ func (g *graph) toposortGenerateCollect(
 		// Return the decorated one if there's any.
 		return decorated, nil
 	}
+	// The decorators might mutate the supplied value, which
+	// is likely to surprise the user, so we warn about it.
+	// The groups are freshly collected so they are fine.
+	for _, slot := range g.provide[key] {
+		if node := g.nodes[slot.id]; node.supply && !key.group {
+			g.warn("type %s supplied by node %q is decorated",
+				key, node.String(slot.id))
+		}
+	}
 	for _, outputSlot := range outputSlots {
 		params, err := g.toposortGenerateGraphNodeID(
 			tp, outputSlot.id)
@@ -382,7 +794,7 @@ func (g *graph) toposortGenerateCollect(
 // toposortGenerateGraphNode generates the execution result
 // of provided graph node.
 func (g *graph) toposortGenerateGraphNode(
-	tp *graphToposort, current graphNode,
+	tp *graphToposort, id int, current graphNode,
 ) (*executionParam, error) {
 	collectNode := &collectParamNode{
 		result: &executionParam{
@@ -390,13 +802,31 @@ func (g *graph) toposortGenerateGraphNode(
 		},
 	}
 	for _, input := range current.input {
+		if input.Lazy || g.missingOptional(input) {
+			continue
+		}
 		key := extractGraphKey(input)
 		_, err := g.toposortGenerateBaseCollect(tp, key)
 		if err != nil {
-			return nil, err
+			return nil, g.toposortBaseError(tp, input, err)
 		}
 	}
 	for _, input := range current.input {
+		if input.Lazy {
+			collectNode.items = append(collectNode.items,
+				g.toposortGenerateLazy(tp, extractGraphKey(input)))
+			continue
+		}
+		if g.missingOptional(input) {
+			collectNode.items = append(collectNode.items,
+				executionCollect{
+					result: &executionParam{
+						params: []reflect.Value{reflect.Zero(input.Type)},
+					},
+					index: 0,
+				})
+			continue
+		}
 		collect, err := g.toposortGenerateCollect(tp, input)
 		if err != nil {
 			return nil, err
@@ -409,33 +839,147 @@ func (g *graph) toposortGenerateGraphNode(
 		result: &executionParam{
 			params: make([]reflect.Value, len(current.output)),
 		},
-		value: current.value,
+		value:    current.value,
+		id:       id,
+		node:     current,
+		position: len(tp.result),
+		scoped:   scopedInputs(current.input),
 	}
 	tp.result = append(tp.result, userNode)
+
+	// Schedule the lazily consumed types after current node,
+	// so that they can be resolved after the node executed.
+	for _, input := range current.input {
+		if !input.Lazy {
+			continue
+		}
+		if err := g.toposortScheduleLazy(tp, input); err != nil {
+			return nil, err
+		}
+	}
 	return userNode.result, nil
 }
 
+// toposortGenerateLazy creates the collect of the Lazy
+// accessor, which resolves the key when it is called.
+func (g *graph) toposortGenerateLazy(
+	tp *graphToposort, key graphNodeKey,
+) executionCollect {
+	lazy := Lazy(func() (reflect.Value, error) {
+		return g.resolve(tp, key)
+	})
+	return executionCollect{
+		result: &executionParam{
+			params: []reflect.Value{reflect.ValueOf(lazy)},
+		},
+		index: 0,
+	}
+}
+
+// resolve retrieves the value of the key that has been
+// constructed in the execution plan.
+func (g *graph) resolve(
+	tp *graphToposort, key graphNodeKey,
+) (reflect.Value, error) {
+	collect, ok := g.lookupCollect(tp, key)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf(
+			"type %s is not scheduled", key)
+	}
+	value := collect.collect()
+	if !value.IsValid() {
+		return reflect.Value{}, fmt.Errorf(
+			"type %s has not been constructed yet", key)
+	}
+	return value, nil
+}
+
+// lookupCollect finds the collect of the key that has
+// been generated in the execution plan.
+func (g *graph) lookupCollect(
+	tp *graphToposort, key graphNodeKey,
+) (executionCollect, bool) {
+	if collect, ok := tp.decorated[key]; ok {
+		return collect, true
+	}
+	if len(g.decorate[key]) > 0 {
+		return executionCollect{}, false
+	}
+	if key.group {
+		result, ok := tp.grouped[key]
+		return executionCollect{result: result}, ok
+	}
+	outputSlots := g.providers(key)
+	if len(outputSlots) != 1 {
+		return executionCollect{}, false
+	}
+	params, ok := tp.outputs[outputSlots[0].id]
+	return executionCollect{
+		result: params,
+		index:  outputSlots[0].index,
+	}, ok
+}
+
+// toposortScheduleLazy schedules the lazily consumed type
+// unless its provider is still being generated, in which
+// case it will be constructed once the provider completes.
+func (g *graph) toposortScheduleLazy(
+	tp *graphToposort, spec Spec,
+) error {
+	key := extractGraphKey(spec)
+	for _, outputSlot := range g.provide[key] {
+		if _, ok := tp.pending[outputSlot.id]; ok {
+			return nil
+		}
+	}
+	spec.Lazy = false
+	_, err := g.toposortGenerateCollect(tp, spec)
+	return err
+}
+
 // toposort evaluates the execution plan for a series of
 // invoked type. The strip of the last node will be the
 // one to collect the values corresponding to the key.
 func (g *graph) toposort(
 	invokes []graphNode,
 ) ([]executionNode, error) {
-	tp := newGraphToposort()
+	return g.toposortWith(newGraphToposort(), invokes)
+}
+
+// toposortWith generates the execution plan with the state of
+// toposort, which can be used for looking up the values after
+// the plan has been generated.
+func (g *graph) toposortWith(
+	tp *graphToposort, invokes []graphNode,
+) ([]executionNode, error) {
+	// Generate the final consumers at last, so that all the
+	// other nodes in the execution plan are executed before.
+	var finals []graphNode
+	for _, invoke := range invokes {
+		if invoke.final {
+			finals = append(finals, invoke)
+		}
+	}
+	if len(finals) > 0 {
+		var ordered []graphNode
+		for _, invoke := range invokes {
+			if !invoke.final {
+				ordered = append(ordered, invoke)
+			}
+		}
+		invokes = append(ordered, finals...)
+	}
 	for _, invoke := range invokes {
-		_, err := g.toposortGenerateGraphNode(tp, invoke)
+		_, err := g.toposortGenerateGraphNode(tp, -1, invoke)
 		if err != nil {
 			// We would like to be able to display the
 			// name of invoked node here, and we will
 			// simply assign "" as the name if we cannot
 			// retrieve the name.
-			name := ""
-			if invoke.format != nil {
-				name = invoke.format.String()
-			}
 			return nil, &ErrDependency{
-				Node: name,
-				Err:  err,
+				Node:    invoke.displayName(),
+				Err:     err,
+				Planned: planNames(tp.result),
 			}
 		}
 	}
@@ -0,0 +1,498 @@
+package core
+
+import (
+	"sort"
+)
+
+// displayName returns the display name of a consumer, which
+// is empty if it cannot be retrieved.
+func (g graphNode) displayName() string {
+	if g.format == nil {
+		return ""
+	}
+	return g.format.String()
+}
+
+// dependencies returns the ids of the nodes which provide or
+// decorate the input, which might be included into the plan
+// because of the input.
+func (g *graph) dependencies(input Spec) []int {
+	key := extractGraphKey(input)
+	var ids []int
+	for _, slot := range g.providers(key) {
+		ids = append(ids, slot.id)
+	}
+	for _, slot := range g.decorate[key] {
+		ids = append(ids, slot.id)
+	}
+	return ids
+}
+
+// depth evaluates the length of the longest dependency chain
+// of the inputs, where the cyclic dependencies are ignored
+// and will be reported while toposorting.
+func (g *graph) depth(
+	input []Spec, memo map[int]int, pending map[int]struct{},
+) int {
+	result := 0
+	for _, spec := range input {
+		if spec.Lazy {
+			continue
+		}
+		for _, id := range g.dependencies(spec) {
+			if _, ok := pending[id]; ok {
+				continue
+			}
+			depth, ok := memo[id]
+			if !ok {
+				pending[id] = struct{}{}
+				depth = g.depth(g.nodes[id].input, memo, pending) + 1
+				delete(pending, id)
+				memo[id] = depth
+			}
+			if depth > result {
+				result = depth
+			}
+		}
+	}
+	return result
+}
+
+// sortByDepth sorts the consumers by the depth of their
+// dependencies stably, in the ascending order.
+func (g *graph) sortByDepth(consumers []graphNode) {
+	memo := make(map[int]int)
+	pending := make(map[int]struct{})
+	depths := make([]int, len(consumers))
+	for i, consumer := range consumers {
+		depths[i] = g.depth(consumer.input, memo, pending)
+	}
+	sort.Stable(&consumersByDepth{
+		consumers: consumers,
+		depths:    depths,
+	})
+}
+
+type consumersByDepth struct {
+	consumers []graphNode
+	depths    []int
+}
+
+func (c *consumersByDepth) Len() int {
+	return len(c.consumers)
+}
+
+func (c *consumersByDepth) Less(i, j int) bool {
+	return c.depths[i] < c.depths[j]
+}
+
+func (c *consumersByDepth) Swap(i, j int) {
+	c.consumers[i], c.consumers[j] = c.consumers[j], c.consumers[i]
+	c.depths[i], c.depths[j] = c.depths[j], c.depths[i]
+}
+
+// WhyIncluded returns the chain of nodes which pulls the
+// providers of the spec into the execution plan, starting
+// from a consumer and ending at the provider of the spec.
+// And nil is returned if the spec is not in the plan.
+//
+// The shortest chain is returned, and the consumers are
+// searched in the order of registration.
+func WhyIncluded(spec Spec, opts ...Option) ([]string, error) {
+	option, _, err := build(opts...)
+	if err != nil {
+		return nil, err
+	}
+	g := option.g
+	target := make(map[int]struct{})
+	for _, slot := range g.provide[extractGraphKey(spec)] {
+		target[slot.id] = struct{}{}
+	}
+
+	// Perform a breadth first search from the consumers, the
+	// consumers are represented as negative ids in parents.
+	type item struct {
+		id   int
+		node graphNode
+	}
+	parents := make(map[int]int)
+	var queue []item
+	for i, consumer := range option.consumers {
+		queue = append(queue, item{id: -i - 1, node: consumer})
+	}
+	for len(queue) > 0 {
+		var current item
+		current, queue = queue[0], queue[1:]
+		for _, input := range current.node.input {
+			for _, id := range g.dependencies(input) {
+				if _, ok := parents[id]; ok {
+					continue
+				}
+				parents[id] = current.id
+				if _, ok := target[id]; ok {
+					return traceIncluded(option, parents, id), nil
+				}
+				queue = append(queue, item{id: id, node: g.nodes[id]})
+			}
+		}
+	}
+	return nil, nil
+}
+
+func traceIncluded(option *option, parents map[int]int, id int) []string {
+	var result []string
+	for id >= 0 {
+		result = append([]string{option.g.nodes[id].String(id)}, result...)
+		id = parents[id]
+	}
+	consumer := option.consumers[-id-1]
+	return append([]string{consumer.displayName()}, result...)
+}
+
+// Plan returns the display names of the nodes that would be
+// executed, in the order of the execution plan. The nodes
+// internal to the framework for collecting values are not
+// included, and nothing is executed.
+func Plan(opts ...Option) ([]string, error) {
+	_, nodes, err := build(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return planNames(nodes), nil
+}
+
+// planNames returns the display names of the user nodes.
+func planNames(nodes []executionNode) []string {
+	var result []string
+	for _, node := range nodes {
+		if userNode, ok := node.(*graphUserNode); ok {
+			result = append(result, userNode.name())
+		}
+	}
+	return result
+}
+
+// unused returns the display names of the nodes not in the
+// execution plan, in the order of registration, skipping the
+// builtin ones.
+func (g *graph) unused(nodes []executionNode) []string {
+	used := make(map[int]struct{})
+	for _, node := range nodes {
+		if userNode, ok := node.(*graphUserNode); ok && userNode.id >= 0 {
+			used[userNode.id] = struct{}{}
+		}
+	}
+	var result []string
+	for id, node := range g.nodes {
+		if _, ok := used[id]; !ok && !node.builtin {
+			result = append(result, node.String(id))
+		}
+	}
+	return result
+}
+
+// name returns the display name of the user node.
+func (n *graphUserNode) name() string {
+	if n.id < 0 {
+		return n.node.displayName()
+	}
+	return n.node.String(n.id)
+}
+
+// TopoOrder returns the output specs of the nodes in the
+// order of the execution plan, the consumers are skipped
+// since they have no output.
+func TopoOrder(opts ...Option) ([]Spec, error) {
+	_, nodes, err := build(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return outputSpecs(nodes), nil
+}
+
+func outputSpecs(nodes []executionNode) []Spec {
+	var result []Spec
+	for _, node := range nodes {
+		if userNode, ok := node.(*graphUserNode); ok && userNode.id >= 0 {
+			result = append(result, userNode.node.output...)
+		}
+	}
+	return result
+}
+
+// Subgraph returns the output specs of the nodes required
+// to construct the spec in the order of execution plan, it
+// is toposorted as if there's only one consumer of the spec.
+func Subgraph(spec Spec, opts ...Option) ([]Spec, error) {
+	option, err := apply(opts...)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := option.g.toposort([]graphNode{{
+		input: []Spec{spec},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return outputSpecs(nodes), nil
+}
+
+// Provided returns the specs provided by the nodes in the
+// order of registration, the decorate ports are skipped and
+// the duplicated specs are listed only once. It inspects the
+// registered nodes, so nothing is toposorted or executed.
+func Provided(opts ...Option) ([]Spec, error) {
+	option, err := apply(opts...)
+	if err != nil {
+		return nil, err
+	}
+	var result []Spec
+	visited := make(map[graphNodeKey]struct{})
+	for _, node := range option.g.nodes {
+		for _, item := range node.output {
+			key := extractGraphKey(item)
+			if _, ok := visited[key]; ok || item.Decorate {
+				continue
+			}
+			visited[key] = struct{}{}
+			result = append(result, Spec{
+				Type: item.Type, Name: item.Name, Group: item.Group,
+			})
+		}
+	}
+	return result, nil
+}
+
+// ProvidedBy returns the outputs of the nodes alongside with
+// the display names of the nodes, in the order of registration.
+// Unlike Provided, the specs provided by multiple nodes are
+// listed once per node, but the decorate ports are still
+// skipped.
+func ProvidedBy(opts ...Option) ([]ProviderInfo, error) {
+	option, err := apply(opts...)
+	if err != nil {
+		return nil, err
+	}
+	var result []ProviderInfo
+	for id, node := range option.g.nodes {
+		for _, item := range node.output {
+			if item.Decorate {
+				continue
+			}
+			result = append(result, ProviderInfo{
+				Node: node.String(id), Spec: item,
+			})
+		}
+	}
+	return result, nil
+}
+
+// Validate checks the wiring of the nodes without executing
+// them, and reports all the problems of a kind at once instead
+// of the first one encountered while building the plan.
+//
+// Every single type provided by more than one node is reported
+// as ErrDuplicates, which would be an ambiguous dependency once
+// the type is consumed, so the conflicts are reported even if
+// the type is not consumed. The defaults overridden by the
+// other nodes are not counted as duplicates, and neither are
+// the decorators or groups.
+//
+// If there's no duplicate, the first decorator decorating a
+// single type not provided by any other node is reported as
+// ErrDecorate, even if the type is not consumed.
+//
+// Finally, every type required transitively by the consumers
+// but not provided is reported as ErrMissing.
+func Validate(opts ...Option) error {
+	option, err := apply(opts...)
+	if err != nil {
+		return err
+	}
+	if err := option.g.checkDuplicates(); err != nil {
+		return err
+	}
+	if err := option.g.checkDecorate(); err != nil {
+		return err
+	}
+	return option.checkMissing()
+}
+
+// checkDuplicates reports the single types provided by more
+// than one node as ErrDuplicates.
+func (g *graph) checkDuplicates() error {
+	var conflicts []*ErrConflict
+	visited := make(map[graphNodeKey]struct{})
+	for _, node := range g.nodes {
+		for _, item := range node.output {
+			key := extractGraphKey(item)
+			if _, ok := visited[key]; ok || item.Group || item.Decorate {
+				continue
+			}
+			visited[key] = struct{}{}
+			slots := g.overrideFallback(g.provide[key])
+			if len(slots) <= 1 {
+				continue
+			}
+			var nodes []string
+			for _, slot := range slots {
+				nodes = append(nodes, g.nodes[slot.id].String(slot.id))
+			}
+			conflicts = append(conflicts, &ErrConflict{
+				Nodes: nodes,
+				Type:  key.String(),
+			})
+		}
+	}
+	if len(conflicts) > 0 {
+		return &ErrDuplicates{Conflicts: conflicts}
+	}
+	return nil
+}
+
+// checkMissing walks the requirements of the consumers
+// transitively, and reports the types not provided as
+// ErrMissing.
+func (o *option) checkMissing() error {
+	g := o.g
+	var missing []string
+	reported := make(map[graphNodeKey]struct{})
+	visited := make(map[int]struct{})
+	queue := append([]graphNode(nil), o.consumers...)
+	for len(queue) > 0 {
+		var node graphNode
+		node, queue = queue[0], queue[1:]
+		for _, input := range node.input {
+			key := extractGraphKey(input)
+			if _, ok := reported[key]; !ok && !input.Group &&
+				!input.Optional && len(g.providers(key)) == 0 {
+				reported[key] = struct{}{}
+				missing = append(missing, key.String())
+			}
+			for _, id := range g.dependencies(input) {
+				if _, ok := visited[id]; !ok {
+					visited[id] = struct{}{}
+					queue = append(queue, g.nodes[id])
+				}
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return &ErrMissing{types: missing}
+	}
+	return nil
+}
+
+// Unresolved returns the specs consumed by the nodes but not
+// provided by any of them, in the order of registration. The
+// groups and optional types are never unresolved.
+//
+// Unlike building the execution plan, which reports only the
+// first missing dependency, all of the gaps are reported, no
+// matter whether the consumer would be executed or not.
+func Unresolved(opts ...Option) ([]Spec, error) {
+	option, err := apply(opts...)
+	if err != nil {
+		return nil, err
+	}
+	var result []Spec
+	visited := make(map[graphNodeKey]struct{})
+	nodes := append(append([]graphNode(nil),
+		option.g.nodes...), option.consumers...)
+	for _, node := range nodes {
+		for _, item := range node.input {
+			key := extractGraphKey(item)
+			if _, ok := visited[key]; ok || item.Group || item.Optional {
+				continue
+			}
+			visited[key] = struct{}{}
+			if len(option.g.providers(key)) == 0 {
+				result = append(result, Spec{
+					Type: item.Type, Name: item.Name,
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// Unconsumed returns the display names of the nodes in the
+// execution plan whose outputs are never consumed by the
+// consumers transitively, e.g. those providing the members
+// discarded by GroupReplace, and the nodes pulled in only to
+// construct them. The nodes are in the order of the plan.
+//
+// A type consumed lazily is considered consumed, since it
+// might be resolved by the consumer.
+func Unconsumed(opts ...Option) ([]string, error) {
+	_, nodes, err := build(opts...)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[*executionParam]struct{})
+	lazy := make(map[graphNodeKey]struct{})
+	consumed := make(map[*graphUserNode]struct{})
+	isConsumed := func(node *graphUserNode) bool {
+		if _, ok := live[node.result]; ok || node.id < 0 {
+			return true
+		}
+		for _, output := range node.node.output {
+			if _, ok := lazy[extractGraphKey(output)]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	// The nodes are visited in the reverse order of the plan
+	// so that the consumed values are propagated backward.
+	// But the lazily consumed types are scheduled after their
+	// consumers, so we must repeat until nothing changes.
+	for changed := true; changed; {
+		changed = false
+		for i := len(nodes) - 1; i >= 0; i-- {
+			switch node := nodes[i].(type) {
+			case *graphUserNode:
+				if _, ok := consumed[node]; ok || !isConsumed(node) {
+					continue
+				}
+				consumed[node] = struct{}{}
+				changed = true
+				live[node.params] = struct{}{}
+				for _, input := range node.node.input {
+					if input.Lazy {
+						lazy[extractGraphKey(input)] = struct{}{}
+					}
+				}
+			case *collectParamNode:
+				if _, ok := live[node.result]; ok {
+					for _, item := range node.items {
+						live[item.result] = struct{}{}
+					}
+				}
+			case *collectGroupNode:
+				if _, ok := live[node.result]; !ok {
+					continue
+				}
+				items := node.items
+				if node.replace && len(items) > 0 {
+					items = items[len(items)-1:]
+				}
+				for _, item := range items {
+					live[item.result] = struct{}{}
+				}
+			}
+		}
+	}
+	var result []string
+	for _, node := range nodes {
+		userNode, ok := node.(*graphUserNode)
+		if !ok {
+			continue
+		}
+		if _, ok := consumed[userNode]; !ok {
+			result = append(result, userNode.name())
+		}
+	}
+	return result, nil
+}
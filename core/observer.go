@@ -0,0 +1,42 @@
+package core
+
+import (
+	"time"
+)
+
+// Observer observes the execution of the nodes, e.g. for
+// measuring the time spent in each of the constructors.
+//
+// The nodes are named by their display names. The finish of
+// a stacked node is observed after the execution of the
+// nodes in its scope, and the observer must be safe for
+// concurrent use when WithConcurrency is specified.
+type Observer interface {
+	OnNodeStart(name string)
+	OnNodeFinish(name string, err error, d time.Duration)
+}
+
+// WithObserver specifies the observer of the execution of the
+// nodes, and no node is observed by default.
+func WithObserver(obs Observer) Option {
+	return func(option *option) {
+		option.observer = obs
+	}
+}
+
+// observe executes the user node while notifying the observer.
+func (rs *runState) observe(userNode *graphUserNode, action runAction) error {
+	name := action.name()
+	rs.observer.OnNodeStart(name)
+	start := time.Now()
+	err := action.exec(rs, userNode.scope(userNode.params.params),
+		userNode.result.params)
+	rs.observer.OnNodeFinish(name, err, time.Since(start))
+	if err != nil {
+		return &ErrExecute{
+			Node: name,
+			Err:  err,
+		}
+	}
+	return nil
+}
@@ -7,6 +7,7 @@ package core
 import (
 	"fmt"
 	"reflect"
+	"strings"
 )
 
 // Spec defines specification of a provided or consumed type
@@ -34,8 +35,53 @@ type Spec struct {
 	//
 	// Error will be generated if a decorate node will also
 	// provides some required type, but no one provides the
-	// type to decorate.
+	// type to decorate, see also ErrDecorate.
 	Decorate bool
+
+	// Order specifies the order of a decorate port among the
+	// decorators of the same type. The decorators are applied
+	// from the lower order to the higher order, and those of
+	// the same order are applied in the order of insertion.
+	//
+	// For a group port which does not decorate, it specifies
+	// the order of the members provided by it in the group
+	// likewise, so the members are collected from the lower
+	// order to the higher order.
+	Order int
+
+	// Lazy specifies whether this port consumes the type or
+	// group lazily.
+	//
+	// A lazy port is not a dependency of the node, and the
+	// value passed to the node will be a Lazy accessor. The
+	// type will be scheduled after the node if it has not
+	// been, so it can be resolved after the node executed,
+	// which is useful for breaking cyclic dependencies.
+	Lazy bool
+
+	// Optional specifies whether this port consumes the single
+	// type optionally, the zero value of the type is passed to
+	// the node if no node is providing the type.
+	Optional bool
+}
+
+// Lazy is the accessor passed to a lazy port. It returns the
+// value when the type has been constructed, or an error if it
+// has not been constructed yet.
+type Lazy func() (reflect.Value, error)
+
+// Resolve retrieves the value of the spec which has been
+// constructed in the execution plan being executed, or an
+// error if it is not scheduled or not constructed yet.
+type Resolve func(Spec) (reflect.Value, error)
+
+// ProviderInfo describes a node providing a type or group.
+type ProviderInfo struct {
+	// Node is the display name of the providing node.
+	Node string
+
+	// Spec is the output spec of the providing node.
+	Spec Spec
 }
 
 // ErrDependency indicates there's dependency error on node.
@@ -45,6 +91,11 @@ type Spec struct {
 type ErrDependency struct {
 	Node string
 	Err  error
+
+	// Planned is the display names of the nodes scheduled in
+	// the execution plan before the error, which is filled
+	// only for the outermost error of the consumer.
+	Planned []string
 }
 
 func (e *ErrDependency) Error() string {
@@ -68,3 +119,94 @@ func (e *ErrExecute) Error() string {
 func (e *ErrExecute) Unwrap() error {
 	return e.Err
 }
+
+// ErrCycle indicates there's a cyclic dependency between the
+// nodes, where the display names of the nodes forming the loop
+// are listed in order, with the first one repeated at the end.
+type ErrCycle struct {
+	Nodes []string
+}
+
+func (e *ErrCycle) Error() string {
+	return fmt.Sprintf("cyclic dependency %s",
+		strings.Join(e.Nodes, " -> "))
+}
+
+// ErrConflict indicates nodes that must not coexist have
+// been provided at the same time.
+type ErrConflict struct {
+	Nodes []string
+
+	// Type is the type that the nodes conflict on, which is
+	// empty if they conflict regardless of the types.
+	Type string
+}
+
+func (e *ErrConflict) Error() string {
+	var nodes []string
+	for _, node := range e.Nodes {
+		nodes = append(nodes, fmt.Sprintf("%q", node))
+	}
+	result := fmt.Sprintf("nodes %s conflict", strings.Join(nodes, ", "))
+	if e.Type != "" {
+		result = fmt.Sprintf("%s on type %s", result, e.Type)
+	}
+	return result
+}
+
+// ErrDuplicates aggregates the conflicts of the types being
+// provided by more than one node, which is reported by Validate.
+type ErrDuplicates struct {
+	Conflicts []*ErrConflict
+}
+
+func (e *ErrDuplicates) Error() string {
+	var conflicts []string
+	for _, conflict := range e.Conflicts {
+		conflicts = append(conflicts, conflict.Error())
+	}
+	return strings.Join(conflicts, "; ")
+}
+
+// ErrMissing lists the types required transitively by the
+// consumers but not provided by any node, which is reported
+// by Validate.
+type ErrMissing struct {
+	types []string
+}
+
+func (e *ErrMissing) Error() string {
+	return fmt.Sprintf("missing dependencies: %s",
+		strings.Join(e.types, ", "))
+}
+
+// Missing returns the missing types, in the order in which
+// they are discovered from the consumers.
+func (e *ErrMissing) Missing() []string {
+	return append([]string(nil), e.types...)
+}
+
+// ErrDecorate indicates a decorator is decorating a type not
+// provided by any node other than the decorators, so that the
+// decorator can never be executed.
+type ErrDecorate struct {
+	// Node is the display name of the decorator.
+	Node string
+
+	// Type is the type being decorated.
+	Type string
+
+	// Provides lists the other types provided by the decorator,
+	// which are unavailable since it cannot be executed.
+	Provides []string
+}
+
+func (e *ErrDecorate) Error() string {
+	result := fmt.Sprintf(
+		"node %q decorates type %s provided by no node", e.Node, e.Type)
+	if len(e.Provides) > 0 {
+		result = fmt.Sprintf("%s, so it cannot provide %s",
+			result, strings.Join(e.Provides, ", "))
+	}
+	return result
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"sync"
+)
+
+// WithConcurrency executes the independent nodes in the
+// execution plan concurrently, with at most n of them being
+// executed at the same time. The nodes are executed
+// sequentially if n is not greater than one, which is the
+// default.
+//
+// The nodes are executed in batches, where a batch consists
+// of the consecutive nodes in the execution plan that do not
+// depend on each other. When a node returns an error, the
+// nodes in the batch that have not been started are skipped,
+// and the first error is returned after the started ones
+// complete. The consumers are still executed one by one in
+// their order, and the groups are still collected in the
+// order of provision.
+func WithConcurrency(n int) Option {
+	return func(option *option) {
+		option.concurrency = n
+	}
+}
+
+// Sequential aggregates a set of options just like Module, but
+// the nodes inserted by them are always executed alone in the
+// calling goroutine, e.g. those with thread affinity. The
+// stacked functions are always executed sequentially.
+func Sequential(opts ...Option) Option {
+	return func(option *option) {
+		begin := len(option.g.nodes)
+		Module(opts...)(option)
+		for id := begin; id < len(option.g.nodes); id++ {
+			action := option.g.nodes[id].value.(runAction)
+			action.barrier = true
+			option.g.nodes[id].value = action
+		}
+	}
+}
+
+// dependsOn returns whether the internal node collects any
+// value from the outputs.
+func dependsOn(
+	node executionNode, outputs map[*executionParam]struct{},
+) bool {
+	var items []executionCollect
+	switch node := node.(type) {
+	case *collectParamNode:
+		items = node.items
+	case *collectGroupNode:
+		items = node.items
+	default:
+		return true
+	}
+	for _, item := range items {
+		if _, ok := outputs[item.result]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sequential returns whether the user node must be executed
+// alone. The consumers are executed in their order, and the
+// nodes with lazy ports might resolve the values of the other
+// nodes, so they are executed alone.
+func (n *graphUserNode) sequential() bool {
+	if n.id < 0 || n.value.(runAction).barrier {
+		return true
+	}
+	for _, input := range n.node.input {
+		if input.Lazy {
+			return true
+		}
+	}
+	return false
+}
+
+// runParallel executes the pending nodes in batches, where
+// the user nodes in a batch are executed concurrently, and
+// the internal nodes are executed in the calling goroutine
+// after the batch they depend on completes.
+func (rs *runState) runParallel() error {
+	var batch []*graphUserNode
+	outputs := make(map[*executionParam]struct{})
+	flush := func() error {
+		err := rs.executeBatch(batch)
+		batch = nil
+		outputs = make(map[*executionParam]struct{})
+		return err
+	}
+	for len(rs.pending) > 0 {
+		var node executionNode
+		node, rs.pending = rs.pending[0], rs.pending[1:]
+		userNode, ok := node.(*graphUserNode)
+		if !ok {
+			if len(batch) > 0 && dependsOn(node, outputs) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if err := node.execute(); err != nil {
+				return rs.fail(err)
+			}
+			continue
+		}
+		if userNode.sequential() {
+			// The stacked functions continue executing the
+			// remaining nodes inside, so the batch must be
+			// completed before them.
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := rs.execute(userNode); err != nil {
+				return err
+			}
+			continue
+		}
+		batch = append(batch, userNode)
+		outputs[userNode.result] = struct{}{}
+	}
+	return flush()
+}
+
+// executeBatch executes the independent user nodes with
+// at most rs.concurrency of them at the same time.
+func (rs *runState) executeBatch(batch []*graphUserNode) error {
+	if len(batch) == 1 {
+		return rs.execute(batch[0])
+	}
+	var wg sync.WaitGroup
+	var once sync.Once
+	var result error
+	failed := make(chan struct{})
+	semaphore := make(chan struct{}, rs.concurrency)
+	for _, node := range batch {
+		semaphore <- struct{}{}
+		select {
+		case <-failed:
+			<-semaphore
+			wg.Wait()
+			return result
+		default:
+		}
+		wg.Add(1)
+		go func(node *graphUserNode) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			if err := rs.execute(node); err != nil {
+				once.Do(func() {
+					result = err
+					close(failed)
+				})
+			}
+		}(node)
+	}
+	wg.Wait()
+	return result
+}
@@ -1,13 +1,53 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sync"
+	"time"
 )
 
 type option struct {
 	g         *graph
 	consumers []graphNode
+
+	// err is the first error generated while applying the
+	// options, which will be reported before toposorting.
+	err error
+
+	// once records the keys of the Once options applied.
+	once map[string]struct{}
+
+	// dependencyOrder indicates the consumers are ordered by
+	// the depth of their dependencies.
+	dependencyOrder bool
+
+	// concurrency is the maximum number of nodes executed
+	// concurrently, and they are executed sequentially if it
+	// is not greater than one.
+	concurrency int
+
+	// observer observes the execution of the nodes, which
+	// might be nil.
+	observer Observer
+
+	// unusedReporter is called with the nodes excluded from
+	// the execution plan, which might be nil.
+	unusedReporter func([]string)
+
+	// stats collects the statistics of the execution, which
+	// might be nil.
+	stats *RunStats
+
+	// contexts derive the context of each run in order.
+	contexts []func(context.Context) (context.Context, context.CancelFunc)
+}
+
+func (o *option) fail(err error) {
+	if o.err == nil {
+		o.err = err
+	}
 }
 
 // Option is the option for performing dependency injection.
@@ -22,56 +62,717 @@ func Module(opts ...Option) Option {
 	}
 }
 
+// NamedModule aggregates a set of options just like Module,
+// but the display names of the nodes inserted by them are
+// prefixed with the name of the module, e.g. "auth: Provide(
+// main.provideTokenizer)", so that the errors are attributed
+// to the module registering the nodes. The names of the nested
+// modules are prefixed from the outermost one.
+func NamedModule(name string, opts ...Option) Option {
+	return func(option *option) {
+		begin, consumerBegin := len(option.g.nodes), len(option.consumers)
+		Module(opts...)(option)
+		for id := begin; id < len(option.g.nodes); id++ {
+			option.g.nodes[id].prefixName(name)
+		}
+		for id := consumerBegin; id < len(option.consumers); id++ {
+			option.consumers[id].prefixName(name)
+		}
+	}
+}
+
+// moduleFormat prefixes the display name of the node with the
+// name of the module inserting it.
+type moduleFormat struct {
+	module string
+	format fmt.Stringer
+}
+
+func (f moduleFormat) String() string {
+	return fmt.Sprintf("%s: %s", f.module, f.format)
+}
+
+// prefixName prefixes the display name of the node and its
+// run action with the name of the module.
+func (g *graphNode) prefixName(module string) {
+	if g.format == nil {
+		return
+	}
+	g.format = moduleFormat{module: module, format: g.format}
+	if action, ok := g.value.(runAction); ok && action.format != nil {
+		action.format = moduleFormat{module: module, format: action.format}
+		g.value = action
+	}
+}
+
+// Once aggregates a set of options just like Module, but the
+// options are only applied for the first time the key is seen
+// while building, so that a module can be included for more
+// than once without providing the types repeatedly.
+func Once(key string, opts ...Option) Option {
+	return func(option *option) {
+		if _, ok := option.once[key]; ok {
+			return
+		}
+		if option.once == nil {
+			option.once = make(map[string]struct{})
+		}
+		option.once[key] = struct{}{}
+		Module(opts...)(option)
+	}
+}
+
+// AssignableMatching allows a single type to be provided by
+// a node providing a type assignable to it, when there's no
+// node providing the type exactly. It is an error if there's
+// more than one such node.
+//
+// This is powerful but risky, since a type might be matched
+// unexpectedly, so it must be enabled explicitly.
+func AssignableMatching() Option {
+	return func(option *option) {
+		option.g.assignable = true
+	}
+}
+
+// InvokeDependencyOrder executes the consumers in the order
+// of the depth of their dependencies, instead of the order of
+// registration. The consumers with the same depth are still
+// executed in the order of registration.
+func InvokeDependencyOrder() Option {
+	return func(option *option) {
+		option.dependencyOrder = true
+	}
+}
+
+// Decorate aggregates a set of options just like Module, but
+// the decorate ports of the nodes inserted by them are marked
+// as declared explicitly.
+func Decorate(opts ...Option) Option {
+	return func(option *option) {
+		begin := len(option.g.nodes)
+		Module(opts...)(option)
+		for id := begin; id < len(option.g.nodes); id++ {
+			option.g.nodes[id].explicit = true
+		}
+	}
+}
+
+// Default aggregates a set of options just like Module, but
+// the single types provided by the nodes inserted by them are
+// the defaults, which are used only when no other node is
+// providing them, so that they can be overridden.
+func Default(opts ...Option) Option {
+	return func(option *option) {
+		begin := len(option.g.nodes)
+		Module(opts...)(option)
+		for id := begin; id < len(option.g.nodes); id++ {
+			option.g.nodes[id].fallback = true
+		}
+	}
+}
+
+// Replace aggregates a set of options just like Module, but
+// the single types provided by the nodes inserted by them
+// replace those provided by the other nodes, e.g. swapping a
+// real database for a fake one in the tests without removing
+// the production module. It is an error if no other node is
+// providing the replaced type.
+func Replace(opts ...Option) Option {
+	return func(option *option) {
+		begin := len(option.g.nodes)
+		Module(opts...)(option)
+		for id := begin; id < len(option.g.nodes); id++ {
+			option.g.nodes[id].override = true
+		}
+	}
+}
+
+// AfterAll aggregates a set of options just like Module, but
+// the consumers inserted by them are executed after all the
+// other nodes in the execution plan, e.g. for flipping the
+// readiness after everything has been constructed.
+func AfterAll(opts ...Option) Option {
+	return func(option *option) {
+		begin := len(option.consumers)
+		Module(opts...)(option)
+		for id := begin; id < len(option.consumers); id++ {
+			option.consumers[id].final = true
+		}
+	}
+}
+
+// Optional aggregates a set of options just like Module, but
+// the single types consumed by the nodes inserted by them are
+// optional, the zero value of the type is passed instead when
+// no node is providing it.
+func Optional(opts ...Option) Option {
+	return func(option *option) {
+		nodes, consumers := len(option.g.nodes), len(option.consumers)
+		Module(opts...)(option)
+		for id := nodes; id < len(option.g.nodes); id++ {
+			option.g.nodes[id].input = optionalInput(
+				option.g.nodes[id].input)
+		}
+		for id := consumers; id < len(option.consumers); id++ {
+			option.consumers[id].input = optionalInput(
+				option.consumers[id].input)
+		}
+	}
+}
+
+func optionalInput(input []Spec) []Spec {
+	result := append([]Spec(nil), input...)
+	for i := range result {
+		result[i].Optional = true
+	}
+	return result
+}
+
+// NameInputs aggregates a set of options just like Module,
+// but the nodes inserted by them consume the type under the
+// name, instead of the one without a name. The decorate ports
+// are left untouched.
+func NameInputs(name string, typ reflect.Type, opts ...Option) Option {
+	rename := func(input []Spec) []Spec {
+		result := append([]Spec(nil), input...)
+		for i := range result {
+			item := result[i]
+			if item.Type == typ && item.Name == "" && !item.Decorate {
+				result[i].Name = name
+			}
+		}
+		return result
+	}
+	return func(option *option) {
+		nodes, consumers := len(option.g.nodes), len(option.consumers)
+		Module(opts...)(option)
+		for id := nodes; id < len(option.g.nodes); id++ {
+			option.g.nodes[id].input = rename(option.g.nodes[id].input)
+		}
+		for id := consumers; id < len(option.consumers); id++ {
+			option.consumers[id].input = rename(option.consumers[id].input)
+		}
+	}
+}
+
+// GroupReplace makes the group of the spec keep only the last
+// provided member instead of accumulating all of them, which
+// is useful for registries where the later members override
+// the earlier ones.
+func GroupReplace(spec Spec) Option {
+	return func(option *option) {
+		option.g.replace[extractGraphKey(spec)] = struct{}{}
+	}
+}
+
+// ExclusiveGroupMembers requires the members of the types a
+// and b not to be collected into the same group, e.g. the
+// incompatible plugins, where the types are the dynamic types
+// of the members. It is checked while collecting the groups,
+// and an ErrConflict is returned if both of them are present.
+func ExclusiveGroupMembers(a, b reflect.Type) Option {
+	return func(option *option) {
+		option.g.exclusive = append(
+			option.g.exclusive, [2]reflect.Type{a, b})
+	}
+}
+
+// NoImplicitDecorate requires the decorate ports of nodes to
+// be declared explicitly by Decorate, which avoids decorating
+// a type accidentally.
+func NoImplicitDecorate() Option {
+	return func(option *option) {
+		option.g.noImplicitDecorate = true
+	}
+}
+
+// NoSupplyShadow requires a type not to be supplied and
+// provided at the same time, which is usually an accident
+// while migrating from one to the other.
+func NoSupplyShadow() Option {
+	return func(option *option) {
+		option.g.noSupplyShadow = true
+	}
+}
+
+// WithUnusedReporter reports the display names of the nodes
+// which are never executed, since they are not required by
+// any consumer, to the function after the execution plan has
+// been generated. This flags the dead wiring in the modules.
+// The nodes inserted under Builtin are never reported.
+func WithUnusedReporter(f func([]string)) Option {
+	return func(option *option) {
+		option.unusedReporter = f
+	}
+}
+
+// RequireErrorReturns requires the nodes not to be constructed
+// by the functions never returning an error, which are marked
+// by Infallible. This enforces the discipline of handling the
+// errors while constructing, if that is the house style.
+func RequireErrorReturns() Option {
+	return func(option *option) {
+		option.g.requireErrorReturns = true
+	}
+}
+
+// Infallible aggregates a set of options just like Module, but
+// the nodes inserted by them are marked as constructed by the
+// functions never returning an error.
+func Infallible(opts ...Option) Option {
+	return func(option *option) {
+		begin := len(option.g.nodes)
+		Module(opts...)(option)
+		for id := begin; id < len(option.g.nodes); id++ {
+			option.g.nodes[id].infallible = true
+		}
+	}
+}
+
+// Builtin aggregates a set of options just like Module, but
+// the nodes inserted by them are marked as inserted by the
+// framework, which are not reported by WithUnusedReporter.
+func Builtin(opts ...Option) Option {
+	return func(option *option) {
+		begin := len(option.g.nodes)
+		Module(opts...)(option)
+		for id := begin; id < len(option.g.nodes); id++ {
+			option.g.nodes[id].builtin = true
+		}
+	}
+}
+
+// OneOf aggregates a set of options just like Module, but
+// requires at most one node to be inserted by them, which is
+// useful when the options are enabled by feature flags.
+//
+// The nodes conflict once they are inserted, even if their
+// outputs are never consumed. The conflict is reported on the
+// first output type shared by the nodes, or regardless of the
+// types if they share none.
+func OneOf(opts ...Option) Option {
+	return func(option *option) {
+		begin := len(option.g.nodes)
+		Module(opts...)(option)
+		if len(option.g.nodes)-begin <= 1 {
+			return
+		}
+		var nodes []string
+		typ := ""
+		seen := make(map[graphNodeKey]struct{})
+		for id := begin; id < len(option.g.nodes); id++ {
+			node := option.g.nodes[id]
+			nodes = append(nodes, node.String(id))
+			for _, item := range node.output {
+				key := extractGraphKey(item)
+				if _, ok := seen[key]; ok && typ == "" {
+					typ = key.String()
+				}
+				seen[key] = struct{}{}
+			}
+		}
+		option.fail(&ErrConflict{Nodes: nodes, Type: typ})
+	}
+}
+
 type runAction struct {
 	format fmt.Stringer
 	exec   func(state *runState, input, output []reflect.Value) error
+
+	// barrier indicates the node must be executed alone in the
+	// calling goroutine, even if executed concurrently.
+	barrier bool
 }
 
 type runState struct {
-	pending []executionNode
+	ctx         context.Context
+	cancel      context.CancelFunc
+	pending     []executionNode
+	info        PlanInfo
+	resolve     Resolve
+	concurrency int
+	observer    Observer
+	stats       *RunStats
+	statsMu     sync.Mutex
+
+	// step is called before executing a user node if there
+	// has been one executed, which might be nil.
+	step     func()
+	executed bool
 }
 
 func (rs *runState) run() error {
+	if rs.concurrency > 1 && rs.step == nil {
+		return rs.runParallel()
+	}
 	for len(rs.pending) > 0 {
 		var node executionNode
 		node, rs.pending = rs.pending[0], rs.pending[1:]
 		if userNode, ok := node.(*graphUserNode); ok {
-			action := userNode.value.(runAction)
-			if err := action.exec(
-				rs, userNode.params.params, userNode.result.params,
-			); err != nil {
-				name := ""
-				if action.format != nil {
-					name = action.format.String()
-				}
-				return &ErrExecute{
-					Node: name,
-					Err:  err,
-				}
+			if rs.step != nil && rs.executed {
+				rs.step()
+			}
+			rs.executed = true
+			if err := rs.execute(userNode); err != nil {
+				return err
 			}
-		} else {
-			node.execute()
+		} else if err := node.execute(); err != nil {
+			return rs.fail(err)
 		}
 	}
 	return nil
 }
 
-// Run performs the dependency injection with specified options.
-func Run(opts ...Option) error {
-	g := newGraph()
+// execute the user node, wrapping the error with the name.
+func (rs *runState) execute(userNode *graphUserNode) error {
+	if err := rs.executeAction(userNode); err != nil {
+		return rs.fail(err)
+	}
+	return nil
+}
+
+// fail cancels the context of the run, so that the nodes in
+// flight can abort as soon as possible, and returns the error.
+func (rs *runState) fail(err error) error {
+	if rs.cancel != nil {
+		rs.cancel()
+	}
+	return err
+}
+
+func (rs *runState) executeAction(userNode *graphUserNode) error {
+	action := userNode.value.(runAction)
+	if err := rs.ctx.Err(); err != nil {
+		return &ErrExecute{
+			Node: action.name(),
+			Err:  err,
+		}
+	}
+	if rs.stats != nil {
+		rs.record(action.name())
+	}
+	if rs.observer != nil {
+		return rs.observe(userNode, action)
+	}
+	if err := action.exec(
+		rs, userNode.scope(userNode.params.params),
+		userNode.result.params,
+	); err != nil {
+		return &ErrExecute{
+			Node: action.name(),
+			Err:  err,
+		}
+	}
+	return nil
+}
+
+// name returns the display name of the action.
+func (action runAction) name() string {
+	if action.format == nil {
+		return ""
+	}
+	return action.format.String()
+}
+
+// Program is a compiled execution plan, which can be run for
+// more than once without generating the plan again.
+type Program struct {
+	nodes       []executionNode
+	info        PlanInfo
+	resolve     Resolve
+	concurrency int
+	observer    Observer
+	stats       *RunStats
+	contexts    []func(context.Context) (context.Context, context.CancelFunc)
+
+	// stepper is the execution being stepped, which is nil
+	// if the program is not being stepped.
+	stepper *programStepper
+}
+
+// PlanInfo is the information of the execution plan.
+type PlanInfo struct {
+	// Nodes is the number of nodes in the execution plan,
+	// excluding the nodes internal to the framework.
+	Nodes int
+
+	// Providers is the number of nodes providing values in
+	// the execution plan, that is, excluding the consumers.
+	Providers int
+}
+
+func newPlanInfo(nodes []executionNode) PlanInfo {
+	var info PlanInfo
+	for _, node := range nodes {
+		if userNode, ok := node.(*graphUserNode); ok {
+			info.Nodes++
+			if userNode.id >= 0 {
+				info.Providers++
+			}
+		}
+	}
+	return info
+}
+
+// apply the options to build the graph.
+func apply(opts ...Option) (*option, error) {
 	option := &option{
-		g: g,
+		g: newGraph(),
 	}
 	Module(opts...)(option)
+	if err := option.validate(); err != nil {
+		return nil, err
+	}
+	return option, nil
+}
+
+// validate the graph after the options have been applied.
+func (o *option) validate() error {
+	if o.err != nil {
+		return o.err
+	}
+	if err := o.g.checkSupplyConflict(); err != nil {
+		return err
+	}
+	if err := o.g.checkImplicitDecorate(); err != nil {
+		return err
+	}
+	if err := o.g.checkSupplyShadow(); err != nil {
+		return err
+	}
+	if err := o.g.checkOverride(); err != nil {
+		return err
+	}
+	if err := o.g.checkErrorReturns(); err != nil {
+		return err
+	}
+	if o.dependencyOrder {
+		o.g.sortByDepth(o.consumers)
+	}
+	return nil
+}
+
+// build the graph with the options and generate the
+// execution plan of the graph.
+func build(opts ...Option) (*option, []executionNode, error) {
+	option, err := apply(opts...)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Generate the execution plan for invoke first.
-	nodes, err := g.toposort(option.consumers)
+	nodes, err := option.g.toposort(option.consumers)
 	if err != nil {
+		return nil, nil, err
+	}
+	return option, nodes, nil
+}
+
+// Compile the options into a program for later execution.
+func Compile(opts ...Option) (*Program, error) {
+	option, err := apply(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return option.compile()
+}
+
+// BuildStats is the statistics of building execution plan.
+type BuildStats struct {
+	// Duration is the time spent on building the graph and
+	// generating the execution plan.
+	Duration time.Duration
+
+	// Nodes is the number of nodes inserted into the graph.
+	Nodes int
+
+	// Steps is the number of nodes in the execution plan,
+	// including the internal nodes for collecting values.
+	Steps int
+}
+
+// BenchmarkBuild builds the execution plan and collects the
+// statistics of it, without executing the execution plan.
+func BenchmarkBuild(opts ...Option) (BuildStats, error) {
+	start := time.Now()
+	option, nodes, err := build(opts...)
+	if err != nil {
+		return BuildStats{}, err
+	}
+	return BuildStats{
+		Duration: time.Since(start),
+		Nodes:    len(option.g.nodes),
+		Steps:    len(nodes),
+	}, nil
+}
+
+// Run executes the compiled execution plan.
+func (p *Program) Run() error {
+	return p.RunContext(context.Background())
+}
+
+// RunContext executes the compiled execution plan, and stops
+// executing the remaining nodes once the context is done, see
+// also RunContext. The context supplied by SupplyContext is
+// derived from ctx, and is canceled once a node fails.
+func (p *Program) RunContext(ctx context.Context) error {
+	derived := ctx
+	for _, derive := range p.contexts {
+		var cancel context.CancelFunc
+		derived, cancel = derive(derived)
+		defer cancel()
+	}
+	runCtx, cancel := context.WithCancel(derived)
+	defer cancel()
+	if err := (&runState{
+		ctx: runCtx, cancel: cancel, pending: p.nodes, info: p.info,
+		resolve: p.resolve, concurrency: p.concurrency,
+		observer: p.observer, stats: p.stats,
+	}).run(); err != nil {
 		return err
 	}
+	if derived != ctx {
+		return derived.Err()
+	}
+	return nil
+}
 
-	// Execute the created execution plan.
-	return (&runState{pending: nodes}).run()
+type programStepper struct {
+	next   chan struct{}
+	result chan programStep
+}
+
+type programStep struct {
+	done bool
+	err  error
+}
+
+// Step executes the next node of the program, so that the
+// state can be inspected between the nodes while debugging.
+// It returns done when the whole program has been executed,
+// or an error has been generated while executing it, and the
+// next step will start executing the program from scratch.
+//
+// The stepped execution runs in another goroutine, so that
+// the stacked functions can be paused inside, and it must be
+// stepped until done, otherwise the goroutine is leaked. The
+// nodes are always stepped sequentially, regardless of the
+// concurrency specified by WithConcurrency.
+func (p *Program) Step() (done bool, err error) {
+	if p.stepper == nil {
+		stepper := &programStepper{
+			next:   make(chan struct{}),
+			result: make(chan programStep),
+		}
+		p.stepper = stepper
+		go func() {
+			rs := &runState{
+				ctx: context.Background(), pending: p.nodes,
+				info: p.info, resolve: p.resolve, observer: p.observer,
+				stats: p.stats,
+			}
+			rs.step = func() {
+				stepper.result <- programStep{}
+				<-stepper.next
+			}
+			err := rs.run()
+			stepper.result <- programStep{done: true, err: err}
+		}()
+	} else {
+		p.stepper.next <- struct{}{}
+	}
+	step := <-p.stepper.result
+	if step.done {
+		p.stepper = nil
+	}
+	return step.done, step.err
+}
+
+// Run performs the dependency injection with specified options.
+func Run(opts ...Option) error {
+	return NewContainer(opts...).Run()
+}
+
+// WithContext derives the context of each run from the one
+// passed to RunContext by f, e.g. canceling it on signals, so
+// that the remaining nodes are not executed once it is done.
+// The returned cancel function is called after the run.
+//
+// The run fails with the error of the derived context if it
+// is done before the run completes, even if all the nodes have
+// been executed, e.g. when a deadline is exceeded by the last
+// node not observing the context.
+func WithContext(
+	f func(context.Context) (context.Context, context.CancelFunc),
+) Option {
+	return func(option *option) {
+		option.contexts = append(option.contexts, f)
+	}
+}
+
+// RunContext performs the dependency injection just like Run,
+// but the context is checked before executing each node, and
+// the context error is returned as the ErrExecute of the node
+// that would have been executed next once it is done.
+//
+// A node being executed is never preempted, so the nodes
+// executed for long should also honor the context themselves.
+func RunContext(ctx context.Context, opts ...Option) error {
+	return NewContainer(opts...).RunContext(ctx)
+}
+
+// Cacheable aggregates options just like Module, but the nodes
+// inserted by them are considered pure, and their outputs are
+// memoized and reused when the program is run again.
+//
+// Only options inserting Provide and Supply nodes might be
+// specified, since the stacked functions must be executed to
+// continue the execution plan.
+func Cacheable(opts ...Option) Option {
+	return cacheable(0, nil, opts...)
+}
+
+// CacheableTTL is like Cacheable, but the memoized outputs
+// expire after the ttl has elapsed since they were produced,
+// and they are produced again when the program is run next
+// time. The current time is retrieved by calling now.
+func CacheableTTL(
+	ttl time.Duration, now func() time.Time, opts ...Option,
+) Option {
+	return cacheable(ttl, now, opts...)
+}
+
+func cacheable(
+	ttl time.Duration, now func() time.Time, opts ...Option,
+) Option {
+	return func(option *option) {
+		begin := len(option.g.nodes)
+		Module(opts...)(option)
+		for id := begin; id < len(option.g.nodes); id++ {
+			action := option.g.nodes[id].value.(runAction)
+			exec := action.exec
+			var cached []reflect.Value
+			var produced time.Time
+			action.exec = func(
+				rs *runState, in, out []reflect.Value,
+			) error {
+				if cached != nil && (now == nil || now().Sub(produced) < ttl) {
+					copy(out, cached)
+					return nil
+				}
+				if err := exec(rs, in, out); err != nil {
+					return err
+				}
+				cached = append([]reflect.Value(nil), out...)
+				if now != nil {
+					produced = now()
+				}
+				return nil
+			}
+			option.g.nodes[id].value = action
+		}
+	}
 }
 
 // Provide a normal constructor function for futher execution.
@@ -80,13 +781,16 @@ func Provide(
 	input, output []Spec, format fmt.Stringer,
 ) Option {
 	return func(option *option) {
-		option.g.insert(graphNode{
+		g := option.g
+		g.insert(graphNode{
 			input:  input,
 			output: output,
 			value: runAction{
 				exec: func(
 					_ *runState, in, out []reflect.Value,
 				) error {
+					release := g.acquire()
+					defer release()
 					output, err := f(in)
 					if err != nil {
 						return err
@@ -102,6 +806,9 @@ func Provide(
 }
 
 // Supply a series of objects to the graph.
+//
+// It is an error if a type other than group is supplied by
+// more than one Supply, even if it has not been consumed.
 func Supply(
 	values []reflect.Value, output []Spec, format fmt.Stringer,
 ) Option {
@@ -118,11 +825,20 @@ func Supply(
 				format: format,
 			},
 			format: format,
+			supply: true,
 		})
 	}
 }
 
 // Stack a function providing objects by calling a callback.
+//
+// The remainder of the execution plan is executed inside the
+// callback, therefore the stacked functions unwind in the
+// strict reverse order of the execution plan. This holds for
+// the stacked functions independent of each other, which are
+// ordered by the deterministic execution plan, and when the
+// nodes are executed concurrently, since the stacked functions
+// are always executed alone.
 func Stack(
 	f func(func([]reflect.Value) error, []reflect.Value) error,
 	input, output []Spec, format fmt.Stringer,
@@ -140,7 +856,8 @@ func Stack(
 						return rs.run()
 					}, in)
 				},
-				format: format,
+				format:  format,
+				barrier: true,
 			},
 			format: format,
 		})
@@ -190,3 +907,111 @@ func Populate(
 		})
 	}
 }
+
+// Providers supplies the information of the nodes providing
+// specified spec, which will be converted by f into the value
+// to supply. The information is collected only when the node
+// is executed, so that nodes inserted after this option will
+// also be listed.
+func Providers(
+	spec Spec, f func([]ProviderInfo) reflect.Value,
+	output Spec, format fmt.Stringer,
+) Option {
+	return func(option *option) {
+		g := option.g
+		key := extractGraphKey(spec)
+		g.insert(graphNode{
+			output: []Spec{output},
+			value: runAction{
+				exec: func(
+					_ *runState, _, out []reflect.Value,
+				) error {
+					var infos []ProviderInfo
+					for _, slot := range g.provide[key] {
+						node := g.nodes[slot.id]
+						infos = append(infos, ProviderInfo{
+							Node: node.String(slot.id),
+							Spec: node.output[slot.index],
+						})
+					}
+					out[0] = f(infos)
+					return nil
+				},
+				format: format,
+			},
+			format: format,
+		})
+	}
+}
+
+// SupplyPlanInfo supplies the information of the execution
+// plan being executed, which will be converted by f into the
+// value to supply.
+func SupplyPlanInfo(
+	f func(PlanInfo) reflect.Value, output Spec, format fmt.Stringer,
+) Option {
+	return func(option *option) {
+		option.g.insert(graphNode{
+			output: []Spec{output},
+			value: runAction{
+				exec: func(
+					rs *runState, _, out []reflect.Value,
+				) error {
+					out[0] = f(rs.info)
+					return nil
+				},
+				format: format,
+			},
+			format: format,
+		})
+	}
+}
+
+// SupplyContext supplies the context of the run, which will
+// be converted by f into the value to supply. The context is
+// derived from the one passed to RunContext, and is canceled
+// as soon as any node returns an error, before the error is
+// propagated, so that the nodes in flight (e.g. the stacked
+// functions and their goroutines) can abort.
+func SupplyContext(
+	f func(context.Context) reflect.Value, output Spec, format fmt.Stringer,
+) Option {
+	return func(option *option) {
+		option.g.insert(graphNode{
+			output: []Spec{output},
+			value: runAction{
+				exec: func(
+					rs *runState, _, out []reflect.Value,
+				) error {
+					out[0] = f(rs.ctx)
+					return nil
+				},
+				format: format,
+			},
+			format: format,
+		})
+	}
+}
+
+// SupplyResolver supplies the Resolve of the execution plan
+// being executed, which will be converted by f into the value
+// to supply.
+func SupplyResolver(
+	f func(Resolve) reflect.Value, output Spec, format fmt.Stringer,
+) Option {
+	return func(option *option) {
+		option.g.insert(graphNode{
+			output: []Spec{output},
+			value: runAction{
+				exec: func(
+					rs *runState, _, out []reflect.Value,
+				) error {
+					out[0] = f(rs.resolve)
+					return nil
+				},
+				format: format,
+			},
+			format: format,
+		})
+	}
+}
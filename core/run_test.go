@@ -0,0 +1,50 @@
+package core_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aegistudio/shaft/core"
+)
+
+var typeInt = reflect.TypeOf(int(0))
+
+// syntheticGraph creates a graph of the specified size, where
+// each node depends on the previous two nodes.
+func syntheticGraph(size int) []core.Option {
+	spec := func(i int) core.Spec {
+		return core.Spec{Type: typeInt, Name: fmt.Sprintf("n%d", i)}
+	}
+	var opts []core.Option
+	for i := 0; i < size; i++ {
+		var input []core.Spec
+		for j := i - 2; j < i; j++ {
+			if j >= 0 {
+				input = append(input, spec(j))
+			}
+		}
+		opts = append(opts, core.Provide(
+			func(in []reflect.Value) ([]reflect.Value, error) {
+				return []reflect.Value{reflect.ValueOf(len(in))}, nil
+			}, input, []core.Spec{spec(i)}, nil))
+	}
+	opts = append(opts, core.Invoke(func([]reflect.Value) error {
+		return nil
+	}, []core.Spec{spec(size - 1)}, nil))
+	return opts
+}
+
+func BenchmarkBuild(b *testing.B) {
+	opts := syntheticGraph(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stats, err := core.BenchmarkBuild(opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if stats.Nodes != 10000 {
+			b.Fatalf("unexpected node count %d", stats.Nodes)
+		}
+	}
+}
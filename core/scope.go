@@ -0,0 +1,49 @@
+package core
+
+import (
+	"reflect"
+)
+
+// NodeScoped is implemented by the values specialized for each
+// node consuming them, e.g. to attribute the calls made through
+// them to the node. The node receives the result of ScopeNode
+// with its position in the execution plan instead of the value
+// itself, which must be of the same type as the value.
+type NodeScoped interface {
+	ScopeNode(position int) interface{}
+}
+
+var typeNodeScoped = reflect.TypeOf((*NodeScoped)(nil)).Elem()
+
+// scopedInputs returns the indices of the inputs which might
+// be specialized by NodeScoped.
+func scopedInputs(input []Spec) []int {
+	var result []int
+	for i, spec := range input {
+		if spec.Lazy || spec.Group || spec.Type.Kind() == reflect.Interface {
+			continue
+		}
+		if spec.Type.Implements(typeNodeScoped) {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+// scope returns the inputs of the node, with the values
+// implementing NodeScoped specialized for the node.
+func (n *graphUserNode) scope(in []reflect.Value) []reflect.Value {
+	if len(n.scoped) == 0 {
+		return in
+	}
+	result := append([]reflect.Value(nil), in...)
+	for _, i := range n.scoped {
+		value := in[i]
+		if !value.IsValid() || (value.Kind() == reflect.Ptr && value.IsNil()) {
+			continue
+		}
+		result[i] = reflect.ValueOf(
+			value.Interface().(NodeScoped).ScopeNode(n.position))
+	}
+	return result
+}
@@ -0,0 +1,25 @@
+package core
+
+// MaxConcurrency limits how many providers are executed at the
+// same time to n with a semaphore, e.g. bounding simultaneous
+// dials to a database when the providers are executed in
+// parallel. The providers are not bounded if n is not positive.
+func MaxConcurrency(n int) Option {
+	return func(option *option) {
+		option.g.semaphore = nil
+		if n > 0 {
+			option.g.semaphore = make(chan struct{}, n)
+		}
+	}
+}
+
+// acquire a slot from the semaphore of the graph, and returns
+// the function for releasing the slot.
+func (g *graph) acquire() func() {
+	semaphore := g.semaphore
+	if semaphore == nil {
+		return func() {}
+	}
+	semaphore <- struct{}{}
+	return func() { <-semaphore }
+}
@@ -0,0 +1,36 @@
+package core
+
+// RunStats is the statistics of executing the execution plan.
+type RunStats struct {
+	// Executions is the number of times each of the nodes has
+	// been executed, keyed by the display name of the node,
+	// which catches the nodes constructed more than once.
+	Executions map[string]int
+}
+
+// WithStats collects the statistics of the execution into
+// the stats, accumulated over each run of the program. The
+// stats must not be shared by the runs executed concurrently.
+func WithStats(stats *RunStats) Option {
+	return func(option *option) {
+		option.stats = stats
+	}
+}
+
+// RunWithStats performs the dependency injection just like
+// Run, and returns the statistics of the execution.
+func RunWithStats(opts ...Option) (RunStats, error) {
+	stats := RunStats{}
+	err := Run(Module(opts...), WithStats(&stats))
+	return stats, err
+}
+
+// record the execution of the node into the statistics.
+func (rs *runState) record(name string) {
+	rs.statsMu.Lock()
+	defer rs.statsMu.Unlock()
+	if rs.stats.Executions == nil {
+		rs.stats.Executions = make(map[string]int)
+	}
+	rs.stats.Executions[name]++
+}
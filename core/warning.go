@@ -0,0 +1,41 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Warnings collects the non-fatal warnings emitted while
+// building and executing the execution plan.
+type Warnings struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+// Warn appends a formatted warning message.
+func (w *Warnings) Warn(format string, args ...interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warnings = append(w.warnings, fmt.Sprintf(format, args...))
+}
+
+// List returns the warnings collected so far.
+func (w *Warnings) List() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.warnings...)
+}
+
+// WithWarnings specifies the sink of warnings emitted while
+// building the execution plan.
+func WithWarnings(w *Warnings) Option {
+	return func(option *option) {
+		option.g.warnings = w
+	}
+}
+
+func (g *graph) warn(format string, args ...interface{}) {
+	if g.warnings != nil {
+		g.warnings.Warn(format, args...)
+	}
+}
@@ -0,0 +1,23 @@
+package shaft
+
+import (
+	"os"
+)
+
+// EnvPrefix is the prefix of the environment variables read
+// by the providers, it can be supplied to the container so
+// that the configurations of an application can be loaded
+// from the environment variables uniformly.
+type EnvPrefix string
+
+// Lookup retrieves the environment variable of the name with
+// the prefix prepended, see also os.LookupEnv.
+func (p EnvPrefix) Lookup(name string) (string, bool) {
+	return os.LookupEnv(string(p) + name)
+}
+
+// Getenv retrieves the environment variable of the name with
+// the prefix prepended, see also os.Getenv.
+func (p EnvPrefix) Getenv(name string) string {
+	return os.Getenv(string(p) + name)
+}
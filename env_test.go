@@ -0,0 +1,26 @@
+package shaft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+type listenAddr string
+
+func TestEnvPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	t.Setenv("SHAFT_TEST_LISTEN", ":8080")
+	var addr listenAddr
+	assert.NoError(shaft.Run(
+		shaft.Supply(shaft.EnvPrefix("SHAFT_TEST_")),
+		shaft.Provide(func(prefix shaft.EnvPrefix) listenAddr {
+			return listenAddr(prefix.Getenv("LISTEN"))
+		}),
+		shaft.Populate(&addr),
+	))
+	assert.Equal(listenAddr(":8080"), addr)
+}
@@ -0,0 +1,48 @@
+package shaft
+
+import (
+	"sync"
+)
+
+// ErrorGroup collects errors from the background goroutines
+// spawned by the providers, following the semantics of
+// golang.org/x/sync/errgroup.
+//
+// The *ErrorGroup is supplied by Run, which waits for all of
+// the goroutines after the remainder of the execution plan has
+// been executed, and returns the first error of them.
+type ErrorGroup struct {
+	wg   sync.WaitGroup
+	once sync.Once
+	err  error
+}
+
+// Go calls the function in a new goroutine, the first non-nil
+// error returned will be returned by Wait.
+func (g *ErrorGroup) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.once.Do(func() {
+				g.err = err
+			})
+		}
+	}()
+}
+
+// Wait blocks until all goroutines have returned, and returns
+// the first non-nil error of them.
+func (g *ErrorGroup) Wait() error {
+	g.wg.Wait()
+	return g.err
+}
+
+func stackErrorGroup(f func(*ErrorGroup) error) error {
+	group := &ErrorGroup{}
+	err := f(group)
+	if waitErr := group.Wait(); err == nil {
+		err = waitErr
+	}
+	return err
+}
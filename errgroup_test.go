@@ -0,0 +1,34 @@
+package shaft_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+func TestErrorGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	errBackground := errors.New("background error")
+	done := make(chan struct{})
+	var events []string
+	err := shaft.Run(
+		shaft.Supply(&events),
+		shaft.Provide(func(group *shaft.ErrorGroup) *C {
+			group.Go(func() error {
+				<-done
+				return errBackground
+			})
+			return &C{}
+		}),
+		shaft.Invoke(func(events *[]string, _ *C) {
+			*events = append(*events, "invoke")
+			close(done)
+		}),
+	)
+	assert.ErrorIs(err, errBackground)
+	assert.Equal([]string{"invoke"}, events)
+}
@@ -0,0 +1,53 @@
+package shaft
+
+import (
+	"errors"
+
+	"github.com/aegistudio/shaft/core"
+)
+
+// ErrorNode is a node in the tree of the node errors, that
+// is core.ErrDependency and core.ErrExecute.
+type ErrorNode struct {
+	// Node is the display name of the node.
+	Node string
+
+	// Execute indicates whether it is an core.ErrExecute,
+	// otherwise it is an core.ErrDependency.
+	Execute bool
+
+	// Err is the error wrapped by the node error.
+	Err error
+
+	// Children are the node errors wrapped by this one.
+	Children []*ErrorNode
+}
+
+// ErrorTree reconstructs the tree of node errors from the
+// error returned by Run, so that the tools can walk the
+// nested node errors programmatically. It returns nil if
+// there's no node error wrapped in the error.
+func ErrorTree(err error) *ErrorNode {
+	for err != nil {
+		switch e := err.(type) {
+		case *core.ErrDependency:
+			return newErrorNode(e.Node, false, e.Err)
+		case *core.ErrExecute:
+			return newErrorNode(e.Node, true, e.Err)
+		}
+		err = errors.Unwrap(err)
+	}
+	return nil
+}
+
+func newErrorNode(name string, execute bool, err error) *ErrorNode {
+	node := &ErrorNode{
+		Node:    name,
+		Execute: execute,
+		Err:     err,
+	}
+	if child := ErrorTree(err); child != nil {
+		node.Children = append(node.Children, child)
+	}
+	return node
+}
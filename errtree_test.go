@@ -0,0 +1,72 @@
+package shaft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+	"github.com/aegistudio/shaft/core"
+)
+
+func TestErrorTree(t *testing.T) {
+	assert := assert.New(t)
+
+	err := shaft.Run(
+		shaft.Provide(provideChainB),
+		shaft.Provide(provideChainC),
+		shaft.Invoke(invokeChainC),
+	)
+	assert.Error(err)
+	tree := shaft.ErrorTree(err)
+	var nodes []string
+	for node := tree; node != nil; {
+		assert.False(node.Execute)
+		nodes = append(nodes, node.Node)
+		if len(node.Children) == 0 {
+			assert.EqualError(node.Err,
+				"type *shaft_test.chainA missing dependency")
+			break
+		}
+		assert.Len(node.Children, 1)
+		node = node.Children[0]
+	}
+	assert.Equal([]string{
+		"Invoke(github.com/aegistudio/shaft_test.invokeChainC)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainC)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainB)",
+	}, nodes)
+	assert.Nil(shaft.ErrorTree(nil))
+}
+
+func TestErrorTreeGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	err := shaft.Run(
+		shaft.Provide(provideHandlerX),
+		shaft.Provide(provideChainB),
+		shaft.Provide(func(*chainB) []handler { return nil }),
+		shaft.Decorate(func(handlers []handler) []handler {
+			return handlers
+		}),
+		shaft.Invoke(func([]handler) {}),
+	)
+	var dependency *core.ErrDependency
+	assert.ErrorAs(err, &dependency)
+	var nodes []string
+	for node := shaft.ErrorTree(err); node != nil; {
+		nodes = append(nodes, node.Node)
+		if len(node.Children) == 0 {
+			assert.EqualError(node.Err,
+				"type *shaft_test.chainA missing dependency")
+			break
+		}
+		node = node.Children[0]
+	}
+	assert.Equal([]string{
+		"Invoke(github.com/aegistudio/shaft_test.TestErrorTreeGroup.func3)",
+		"Decorate(github.com/aegistudio/shaft_test.TestErrorTreeGroup.func2)",
+		"Provide(github.com/aegistudio/shaft_test.TestErrorTreeGroup.func1)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainB)",
+	}, nodes)
+}
@@ -0,0 +1,47 @@
+package shaft
+
+import (
+	"github.com/aegistudio/shaft/core"
+)
+
+// Features is the set of enabled feature flags, which can be
+// injected after enabling them by EnableFeature, so that the
+// providers query the same set instead of reading the config
+// on their own.
+type Features map[string]struct{}
+
+// Enabled returns whether the feature has been enabled.
+func (f Features) Enabled(name string) bool {
+	_, ok := f[name]
+	return ok
+}
+
+// enabledFeature is the group member of an enabled feature.
+type enabledFeature string
+
+func provideFeatures(features []enabledFeature) Features {
+	result := make(Features)
+	for _, feature := range features {
+		result[string(feature)] = struct{}{}
+	}
+	return result
+}
+
+// EnableFeature enables the features in the Features, and it
+// can be specified for more than once to enable features from
+// different sources, e.g. from config and from command line.
+func EnableFeature(names ...string) Option {
+	features := make([]enabledFeature, 0, len(names))
+	for _, name := range names {
+		features = append(features, enabledFeature(name))
+	}
+	option, err := trySupply(caller(1), features)
+	if err != nil {
+		panic(err.Error())
+	}
+	return Module(
+		core.Once("shaft.Features",
+			convertProvider(provideFeatures).builtin().option(opProvide)),
+		option,
+	)
+}
@@ -0,0 +1,42 @@
+package shaft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+type cacheBackend string
+
+func provideCacheBackend(features shaft.Features) cacheBackend {
+	if features.Enabled("redis") {
+		return "redis"
+	}
+	return "memory"
+}
+
+func TestEnableFeature(t *testing.T) {
+	assert := assert.New(t)
+
+	var backend cacheBackend
+	assert.NoError(shaft.Run(
+		shaft.EnableFeature("metrics"),
+		shaft.Provide(provideCacheBackend),
+		shaft.Populate(&backend),
+	))
+	assert.Equal(cacheBackend("memory"), backend)
+
+	var features shaft.Features
+	assert.NoError(shaft.Run(
+		shaft.EnableFeature("metrics"),
+		shaft.EnableFeature("redis"),
+		shaft.Provide(provideCacheBackend),
+		shaft.Populate(&backend, &features),
+	))
+	assert.Equal(cacheBackend("redis"), backend)
+	assert.Equal(shaft.Features{
+		"metrics": {}, "redis": {},
+	}, features)
+}
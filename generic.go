@@ -0,0 +1,32 @@
+package shaft
+
+// Get runs the dependency injection with the options, and
+// returns the value of T populated from it, which is like
+// declaring a variable of T and running the options with
+// Populate of its pointer.
+func Get[T any](opts ...Option) (T, error) {
+	var result T
+	if err := Run(Module(opts...), Populate(&result)); err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
+
+// Invoke1 invokes the function consuming a value of A, which
+// is like Invoke, but the signature is checked while compiling.
+func Invoke1[A any](fn func(A) error) Option {
+	return Invoke(fn)
+}
+
+// Invoke2 invokes the function consuming the values of A and
+// B, see also Invoke1.
+func Invoke2[A, B any](fn func(A, B) error) Option {
+	return Invoke(fn)
+}
+
+// Invoke3 invokes the function consuming the values of A, B
+// and C, see also Invoke1.
+func Invoke3[A, B, C any](fn func(A, B, C) error) Option {
+	return Invoke(fn)
+}
@@ -0,0 +1,61 @@
+package shaft_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+func TestGet(t *testing.T) {
+	assert := assert.New(t)
+
+	db, err := shaft.Get[*database](shaft.Provide(provideDatabase))
+	assert.NoError(err)
+	assert.Equal("postgres://production", db.addr)
+
+	handlers, err := shaft.Get[[]handler](
+		shaft.Provide(provideHandlerX),
+		shaft.Provide(provideHandlerY),
+	)
+	assert.NoError(err)
+	assert.Len(handlers, 2)
+
+	db, err = shaft.Get[*database]()
+	assert.Error(err)
+	assert.Nil(db)
+}
+
+func TestInvokeN(t *testing.T) {
+	assert := assert.New(t)
+
+	var addr string
+	var count int
+	assert.NoError(shaft.Run(
+		shaft.Provide(provideDatabase),
+		shaft.Provide(provideHandlerX),
+		shaft.Supply(3),
+		shaft.Invoke1(func(db *database) error {
+			addr = db.addr
+			return nil
+		}),
+		shaft.Invoke2(func(db *database, handlers []handler) error {
+			count += len(handlers)
+			return nil
+		}),
+		shaft.Invoke3(func(db *database, handlers []handler, n int) error {
+			count += n
+			return nil
+		}),
+	))
+	assert.Equal("postgres://production", addr)
+	assert.Equal(4, count)
+
+	errInvoke := errors.New("invoke failed")
+	assert.ErrorIs(shaft.Run(
+		shaft.Provide(provideDatabase),
+		shaft.Invoke1(func(*database) error { return errInvoke }),
+	), errInvoke)
+}
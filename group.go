@@ -0,0 +1,162 @@
+package shaft
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/aegistudio/shaft/core"
+)
+
+// entryOp stores the key of map entry alongside with op.
+type entryOp struct {
+	op  op
+	typ reflect.Type
+	key string
+}
+
+func (o entryOp) String() string {
+	return fmt.Sprintf("%s(%s[%s])", o.op, o.typ, o.key)
+}
+
+// ProvideMapAsGroup provides each entry of the map as a member
+// of the group []T, which is converted from the entry by f.
+//
+// The members are provided in the order of the keys' string
+// representation, so that the group is collected in a stable
+// order regardless of the iteration order of map.
+//
+// The members are constructed by the framework, so they are
+// exempt from RequireErrorReturns, although f cannot return an
+// error, just like Partitioned.
+func ProvideMapAsGroup[K comparable, V, T any](
+	m map[K]V, f func(K, V) T,
+) Option {
+	typ := reflect.TypeOf([]T(nil))
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	var opts []Option
+	for _, key := range keys {
+		key, value := key, m[key]
+		opts = append(opts, core.Provide(
+			func([]reflect.Value) ([]reflect.Value, error) {
+				return []reflect.Value{
+					reflect.ValueOf([]T{f(key, value)}),
+				}, nil
+			}, nil, []core.Spec{convertSingle(typ)},
+			entryOp{
+				op:  opProvideMapAsGroup,
+				typ: typ,
+				key: fmt.Sprint(key),
+			},
+		))
+	}
+	return Module(opts...)
+}
+
+// ProvideGroup provides a function as constructor just like
+// Provide, but the members of the groups provided by it are
+// of the specified order, e.g. the priority of a middleware.
+//
+// The members are collected from the lower order to the higher
+// order, and those of the same order are collected in the order
+// of provision. The members provided by Provide are of order 0.
+func ProvideGroup(order int, f interface{}) Option {
+	p := convertProvider(f)
+	group := false
+	for i := range p.out {
+		if p.out[i].Group && !p.out[i].Decorate {
+			p.out[i].Order = order
+			group = true
+		}
+	}
+	if !group {
+		panic(fmt.Sprintf("func %v must provide group", f))
+	}
+	return p.option(opProvideGroup)
+}
+
+// AugmentGroup provides a function as decorator of groups,
+// but the members returned by the function are appended to
+// the group instead of replacing it, e.g. registering the
+// instrumentation handlers derived from the user ones. The
+// function must only decorate groups.
+func AugmentGroup(f interface{}) Option {
+	p := convertProvider(f)
+	var sources []int
+	for _, out := range p.out {
+		if !out.Decorate || !out.Group {
+			panic(fmt.Sprintf("func %v must only decorate groups", f))
+		}
+		for j, in := range p.in {
+			if in == out {
+				sources = append(sources, j)
+				break
+			}
+		}
+	}
+	call := p.call
+	p.call = func(in []reflect.Value) ([]reflect.Value, error) {
+		out, err := call(in)
+		if err != nil {
+			return nil, err
+		}
+		for i, j := range sources {
+			// Copy the members so that the undecorated group
+			// is never written through by appending.
+			members := reflect.MakeSlice(out[i].Type(), 0,
+				in[j].Len()+out[i].Len())
+			members = reflect.AppendSlice(members, in[j])
+			out[i] = reflect.AppendSlice(members, out[i])
+		}
+		return out, nil
+	}
+	return core.Decorate(p.option(opAugmentGroup))
+}
+
+// GroupReplace makes the group []T keep only the last provided
+// member instead of accumulating all of them, so that a later
+// member overrides the earlier ones.
+func GroupReplace[T any]() Option {
+	return core.GroupReplace(convertSingle(reflect.TypeOf([]T(nil))))
+}
+
+// ExclusiveGroupMembers requires the members of type A and B
+// not to coexist in any group, e.g. two incompatible plugins
+// registered into the same group, see also
+// core.ExclusiveGroupMembers.
+func ExclusiveGroupMembers[A, B any]() Option {
+	return core.ExclusiveGroupMembers(
+		reflect.TypeOf((*A)(nil)).Elem(),
+		reflect.TypeOf((*B)(nil)).Elem(),
+	)
+}
+
+// StreamGroup provides the members of the group []T emitted by
+// the function while it is running, which is useful when the
+// number of members is known only at runtime, e.g. discovering
+// the plugins from a directory.
+//
+// The emit function is safe to be called concurrently, but it
+// must not be called after the function has returned. The
+// emitted members are discarded if the function fails.
+func StreamGroup[T any](f func(emit func(T)) error) Option {
+	return Provide(func() ([]T, error) {
+		var mu sync.Mutex
+		var result []T
+		if err := f(func(member T) {
+			mu.Lock()
+			defer mu.Unlock()
+			result = append(result, member)
+		}); err != nil {
+			return nil, err
+		}
+		return result, nil
+	})
+}
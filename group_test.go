@@ -0,0 +1,191 @@
+package shaft_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+type plugin string
+
+func TestProvideMapAsGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	var plugins []plugin
+	assert.NoError(shaft.Run(
+		shaft.ProvideMapAsGroup(map[string]int{
+			"c": 3, "a": 1, "b": 2,
+		}, func(key string, value int) plugin {
+			return plugin(fmt.Sprintf("%s=%d", key, value))
+		}),
+		shaft.Supply([]plugin{"d=4"}),
+		shaft.Populate(&plugins),
+	))
+	assert.Equal([]plugin{"a=1", "b=2", "c=3", "d=4"}, plugins)
+}
+
+func TestGroupReplace(t *testing.T) {
+	assert := assert.New(t)
+
+	var plugins []plugin
+	var handlers []handler
+	assert.NoError(shaft.Run(
+		shaft.GroupReplace[plugin](),
+		shaft.Supply([]plugin{"a=1"}),
+		shaft.Supply([]plugin{"b=2", "c=3"}),
+		shaft.Provide(provideHandlerX),
+		shaft.Provide(provideHandlerY),
+		shaft.Populate(&plugins, &handlers),
+	))
+	assert.Equal([]plugin{"c=3"}, plugins)
+	assert.Len(handlers, 2)
+}
+
+func providePluginA(*chainA) []plugin {
+	return []plugin{"a=1"}
+}
+
+func providePluginB() []plugin {
+	return []plugin{"b=2"}
+}
+
+func TestUnconsumed(t *testing.T) {
+	assert := assert.New(t)
+
+	nodes, err := shaft.Unconsumed(
+		shaft.GroupReplace[plugin](),
+		shaft.Provide(provideChainA),
+		shaft.Provide(providePluginA),
+		shaft.Provide(providePluginB),
+		shaft.Invoke(func([]plugin) {}),
+	)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"Provide(github.com/aegistudio/shaft_test.provideChainA)",
+		"Provide(github.com/aegistudio/shaft_test.providePluginA)",
+	}, nodes)
+}
+
+type authA struct{}
+
+func (authA) handle() string { return "a" }
+
+type authB struct{}
+
+func (authB) handle() string { return "b" }
+
+func provideAuthA() []handler {
+	return []handler{authA{}}
+}
+
+func provideAuthB() []handler {
+	return []handler{authB{}}
+}
+
+func TestExclusiveGroupMembers(t *testing.T) {
+	assert := assert.New(t)
+
+	var handlers []handler
+	assert.NoError(shaft.Run(
+		shaft.ExclusiveGroupMembers[authA, authB](),
+		shaft.Provide(provideAuthA),
+		shaft.Provide(provideHandlerX),
+		shaft.Populate(&handlers),
+	))
+	assert.Len(handlers, 2)
+
+	err := shaft.Run(
+		shaft.ExclusiveGroupMembers[authA, authB](),
+		shaft.Provide(provideAuthA),
+		shaft.Provide(provideHandlerX),
+		shaft.Provide(provideAuthB),
+		shaft.Populate(&handlers),
+	)
+	assert.EqualError(err, fmt.Sprintf("nodes %q, %q conflict on type %s",
+		"Provide(github.com/aegistudio/shaft_test.provideAuthA)",
+		"Provide(github.com/aegistudio/shaft_test.provideAuthB)",
+		"[]shaft_test.handler"))
+}
+
+func TestStreamGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	var plugins []plugin
+	assert.NoError(shaft.Run(
+		shaft.StreamGroup(func(emit func(plugin)) error {
+			for _, name := range []plugin{"a", "b", "c"} {
+				emit(name)
+			}
+			return nil
+		}),
+		shaft.Supply([]plugin{"d"}),
+		shaft.Invoke(func(members []plugin) {
+			plugins = members
+		}),
+	))
+	assert.Equal([]plugin{"a", "b", "c", "d"}, plugins)
+
+	errDiscover := fmt.Errorf("discover failed")
+	assert.ErrorIs(shaft.Run(
+		shaft.StreamGroup(func(emit func(plugin)) error {
+			emit("a")
+			return errDiscover
+		}),
+		shaft.Invoke(func([]plugin) {}),
+	), errDiscover)
+}
+
+func TestProvideGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	var plugins []plugin
+	assert.NoError(shaft.Run(
+		shaft.ProvideGroup(10, func() []plugin {
+			return []plugin{"recover"}
+		}),
+		shaft.Supply([]plugin{"auth"}),
+		shaft.ProvideGroup(-10, func() []plugin {
+			return []plugin{"trace", "log"}
+		}),
+		shaft.ProvideGroup(10, func() []plugin {
+			return []plugin{"metrics"}
+		}),
+		shaft.Populate(&plugins),
+	))
+	assert.Equal([]plugin{
+		"trace", "log", "auth", "recover", "metrics",
+	}, plugins)
+
+	assert.Panics(func() {
+		shaft.ProvideGroup(1, provideDatabase)
+	})
+}
+
+func TestAugmentGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	var names []string
+	assert.NoError(shaft.Run(
+		shaft.Provide(provideHandlerX),
+		shaft.AugmentGroup(func(handlers []handler) []handler {
+			count := len(handlers)
+			return []handler{handlerFunc(func() string {
+				return fmt.Sprintf("metrics(%d)", count)
+			})}
+		}),
+		shaft.Provide(provideHandlerY),
+		shaft.Invoke(func(handlers []handler) {
+			for _, h := range handlers {
+				names = append(names, h.handle())
+			}
+		}),
+	))
+	assert.Equal([]string{"x", "y", "metrics(2)"}, names)
+
+	assert.Panics(func() {
+		shaft.AugmentGroup(func(db *database) *database { return db })
+	})
+}
@@ -0,0 +1,60 @@
+package shaft
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/aegistudio/shaft/core"
+)
+
+// HealthCheck is a named health check function, the health
+// checks are contributed by ProvideHealthCheck as the group
+// []HealthCheck, which can be consumed by e.g. a readiness
+// endpoint of the server.
+type HealthCheck struct {
+	Name  string
+	Check func(context.Context) error
+}
+
+var typeHealthCheck = reflect.TypeOf((func(context.Context) error)(nil))
+
+// sortHealthChecks sorts the health checks by their names, so
+// that they are consumed in a consistent order regardless of
+// the order of registration.
+func sortHealthChecks(checks []HealthCheck) []HealthCheck {
+	sort.SliceStable(checks, func(i, j int) bool {
+		return checks[i].Name < checks[j].Name
+	})
+	return checks
+}
+
+// ProvideHealthCheck provides a function constructing the
+// health check function as a member of []HealthCheck with the
+// name, the function must return func(context.Context) error
+// and an optional error.
+func ProvideHealthCheck(name string, f interface{}) Option {
+	p := convertProvider(f)
+	if len(p.out) != 1 || p.out[0].Type != typeHealthCheck {
+		panic(fmt.Sprintf(
+			"func %v must provide func(context.Context) error", f))
+	}
+	p.out = []core.Spec{convertSingle(reflect.TypeOf([]HealthCheck(nil)))}
+	call := p.call
+	p.call = func(in []reflect.Value) ([]reflect.Value, error) {
+		out, err := call(in)
+		if err != nil {
+			return nil, err
+		}
+		return []reflect.Value{reflect.ValueOf([]HealthCheck{{
+			Name:  name,
+			Check: out[0].Interface().(func(context.Context) error),
+		}})}, nil
+	}
+	return Module(
+		core.Once("shaft.HealthCheck", core.Decorate(
+			convertProvider(sortHealthChecks).builtin().option(opDecorate))),
+		p.option(opProvideHealthCheck),
+	)
+}
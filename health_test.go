@@ -0,0 +1,44 @@
+package shaft_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+func TestProvideHealthCheck(t *testing.T) {
+	assert := assert.New(t)
+
+	errDatabase := errors.New("database unreachable")
+	var checks []shaft.HealthCheck
+	assert.NoError(shaft.Run(
+		shaft.Provide(provideChainA),
+		shaft.ProvideHealthCheck("database", func(
+			*chainA,
+		) func(context.Context) error {
+			return func(context.Context) error {
+				return errDatabase
+			}
+		}),
+		shaft.ProvideHealthCheck("cache", func() func(context.Context) error {
+			return func(context.Context) error { return nil }
+		}),
+		shaft.ProvideHealthCheck("broker", func() (
+			func(context.Context) error, error,
+		) {
+			return func(context.Context) error { return nil }, nil
+		}),
+		shaft.Populate(&checks),
+	))
+	var names []string
+	for _, check := range checks {
+		names = append(names, check.Name)
+	}
+	assert.Equal([]string{"broker", "cache", "database"}, names)
+	assert.NoError(checks[0].Check(context.Background()))
+	assert.ErrorIs(checks[2].Check(context.Background()), errDatabase)
+}
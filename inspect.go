@@ -0,0 +1,175 @@
+package shaft
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aegistudio/shaft/core"
+)
+
+// ProviderList lists the nodes providing T, which can be
+// injected after registering ListProviders[T].
+//
+// The list is metadata of the providers, and consuming it
+// will not cause any of the providers to be executed.
+type ProviderList[T any] struct {
+	Providers []ProviderInfo
+}
+
+// ListProviders supplies the ProviderList[T] listing the
+// nodes providing T, or the group members of T if T is a
+// slice. Nodes registered after this option are also listed.
+func ListProviders[T any]() Option {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	return core.Providers(
+		convertSingle(typ),
+		func(infos []core.ProviderInfo) reflect.Value {
+			return reflect.ValueOf(ProviderList[T]{
+				Providers: infos,
+			})
+		},
+		convertSingle(reflect.TypeOf(ProviderList[T]{})),
+		valuesOp{op: opListProviders, types: []reflect.Type{typ}},
+	)
+}
+
+// inspected seeds the options with the nodes supplied by Run,
+// so that the inspections agree with the execution.
+func inspected(opts []Option) Option {
+	return core.Module(builtins(time.Now(), &Warnings{}), Module(opts...))
+}
+
+// WhyIncluded returns the chain of nodes which pulls the type
+// into the execution plan, see also core.WhyIncluded.
+func WhyIncluded(typ reflect.Type, opts ...Option) ([]string, error) {
+	return core.WhyIncluded(convertSingle(typ), inspected(opts))
+}
+
+// TopoOrder returns the output specs in the order of the
+// execution plan, see also core.TopoOrder. The plan includes
+// the nodes supplied by Run.
+func TopoOrder(opts ...Option) ([]core.Spec, error) {
+	return core.TopoOrder(inspected(opts))
+}
+
+// Subgraph returns the output specs of the nodes required to
+// construct the type, see also core.Subgraph.
+func Subgraph(typ reflect.Type, opts ...Option) ([]core.Spec, error) {
+	return core.Subgraph(convertSingle(typ), inspected(opts))
+}
+
+// ProvidedBy is just a simple forwarding of core.ProvidedBy.
+func ProvidedBy(opts ...Option) ([]ProviderInfo, error) {
+	return core.ProvidedBy(opts...)
+}
+
+// Validate checks the options without executing them, see
+// also core.Validate. The types supplied by Run, e.g. Context
+// and *Lifecycle, are never reported missing.
+func Validate(opts ...Option) error {
+	return core.Validate(inspected(opts))
+}
+
+// Provided is just a simple forwarding of core.Provided.
+func Provided(opts ...Option) ([]core.Spec, error) {
+	return core.Provided(opts...)
+}
+
+// Unresolved returns the specs consumed but never provided,
+// see also core.Unresolved. The types supplied by Run are
+// never reported.
+func Unresolved(opts ...Option) ([]core.Spec, error) {
+	return core.Unresolved(inspected(opts))
+}
+
+// Unconsumed is just a simple forwarding of core.Unconsumed.
+func Unconsumed(opts ...Option) ([]string, error) {
+	return core.Unconsumed(opts...)
+}
+
+// Mermaid is just a simple forwarding of core.Mermaid.
+func Mermaid(opts ...Option) (string, error) {
+	return core.Mermaid(opts...)
+}
+
+// ExportDOT is just a simple forwarding of core.ExportDOT.
+func ExportDOT(w io.Writer, opts ...Option) error {
+	return core.ExportDOT(w, opts...)
+}
+
+// Plan returns the display names of the nodes in the order of
+// the execution plan, see also core.Plan. The plan includes the
+// nodes supplied by Run.
+func Plan(opts ...Option) ([]string, error) {
+	return core.Plan(inspected(opts))
+}
+
+// ModuleContract asserts the module provides at least the
+// listed types, so that a library can lock the public types
+// of its module. Each of the listed types is either specified
+// by a reflect.Type or a pointer to it, e.g. new(T).
+func ModuleContract(opts []Option, provides ...interface{}) error {
+	specs, err := core.Provided(opts...)
+	if err != nil {
+		return err
+	}
+	provided := make(map[core.Spec]struct{})
+	for _, spec := range specs {
+		provided[spec] = struct{}{}
+	}
+	var missing []string
+	for _, item := range provides {
+		typ := convertType(item)
+		if _, ok := provided[convertSingle(typ)]; !ok {
+			missing = append(missing, typ.String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("module does not provide %s",
+			strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// CheckInterfaceReturns lints the providers declaring an
+// interface as their output, and returns a warning for each
+// of them. Such a provider might return a nil pointer of the
+// concrete type, which is a non-nil interface value and will
+// not be caught by the consumers checking against nil. It is
+// suggested to return the concrete types instead.
+//
+// The framework has no way to tell whether the provider would
+// really do so, so the warnings are just hints.
+func CheckInterfaceReturns(opts ...Option) ([]string, error) {
+	infos, err := core.ProvidedBy(opts...)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, info := range infos {
+		typ := info.Spec.Type
+		if info.Spec.Group {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Interface {
+			continue
+		}
+		result = append(result, fmt.Sprintf(
+			"node %q provides interface %s, "+
+				"consider returning the concrete type instead",
+			info.Node, typ))
+	}
+	return result, nil
+}
+
+func supplyPlanInfo() Option {
+	typ := reflect.TypeOf(PlanInfo{})
+	return core.SupplyPlanInfo(func(info PlanInfo) reflect.Value {
+		return reflect.ValueOf(info)
+	}, convertSingle(typ), valuesOp{
+		op: opSupply, types: []reflect.Type{typ},
+	})
+}
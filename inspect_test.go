@@ -0,0 +1,447 @@
+package shaft_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+	"github.com/aegistudio/shaft/core"
+)
+
+type handler interface {
+	handle() string
+}
+
+type handlerFunc func() string
+
+func (f handlerFunc) handle() string {
+	return f()
+}
+
+func provideHandlerX() []handler {
+	return []handler{handlerFunc(func() string { return "x" })}
+}
+
+func provideHandlerY() []handler {
+	return []handler{handlerFunc(func() string { return "y" })}
+}
+
+func TestListProviders(t *testing.T) {
+	assert := assert.New(t)
+
+	var names []string
+	assert.NoError(shaft.Run(
+		shaft.Provide(provideHandlerX),
+		shaft.ListProviders[[]handler](),
+		shaft.Invoke(func(list shaft.ProviderList[[]handler]) {
+			for _, info := range list.Providers {
+				assert.True(info.Spec.Group)
+				names = append(names, info.Node)
+			}
+		}),
+		shaft.Provide(provideHandlerY),
+	))
+	assert.Equal([]string{
+		"Provide(github.com/aegistudio/shaft_test.provideHandlerX)",
+		"Provide(github.com/aegistudio/shaft_test.provideHandlerY)",
+	}, names)
+}
+
+func provideChainA() *chainA {
+	return &chainA{}
+}
+
+func provideChainB(*chainA) *chainB {
+	return &chainB{}
+}
+
+func provideChainC(*chainB) *chainC {
+	return &chainC{}
+}
+
+func invokeChainC(*chainC) {}
+
+func TestWhyIncluded(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := []shaft.Option{
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideChainB),
+		shaft.Provide(provideChainC),
+		shaft.Provide(redundantObjectC),
+		shaft.Invoke(invokeChainC),
+	}
+	chain, err := shaft.WhyIncluded(
+		reflect.TypeOf(&chainA{}), opts...)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"Invoke(github.com/aegistudio/shaft_test.invokeChainC)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainC)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainB)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainA)",
+	}, chain)
+	chain, err = shaft.WhyIncluded(reflect.TypeOf(&C{}), opts...)
+	assert.NoError(err)
+	assert.Nil(chain)
+}
+
+func TestTopoOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	order, err := shaft.TopoOrder(
+		shaft.Provide(provideChainC),
+		shaft.Provide(provideChainB),
+		shaft.Provide(provideChainA),
+		shaft.Provide(redundantObjectC),
+		shaft.Invoke(invokeChainC),
+	)
+	assert.NoError(err)
+	var types []reflect.Type
+	for _, spec := range order {
+		types = append(types, spec.Type)
+	}
+	assert.Equal([]reflect.Type{
+		reflect.TypeOf(&chainA{}),
+		reflect.TypeOf(&chainB{}),
+		reflect.TypeOf(&chainC{}),
+	}, types)
+}
+
+func TestSubgraph(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := []shaft.Option{
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideChainB),
+		shaft.Provide(provideChainC),
+		shaft.Provide(redundantObjectC),
+		shaft.Invoke(invokeChainC),
+	}
+	specs, err := shaft.Subgraph(reflect.TypeOf(&chainB{}), opts...)
+	assert.NoError(err)
+	var types []reflect.Type
+	for _, spec := range specs {
+		types = append(types, spec.Type)
+	}
+	assert.Equal([]reflect.Type{
+		reflect.TypeOf(&chainA{}),
+		reflect.TypeOf(&chainB{}),
+	}, types)
+}
+
+func TestUnresolved(t *testing.T) {
+	assert := assert.New(t)
+
+	specs, err := shaft.Unresolved(
+		shaft.Provide(provideChainB),
+		shaft.Provide(provideChainC),
+		shaft.Provide(func(*chainA, handler) []handler {
+			return nil
+		}),
+		shaft.Invoke(func([]handler, *chainC, *config) {}),
+	)
+	assert.NoError(err)
+	var types []reflect.Type
+	for _, spec := range specs {
+		types = append(types, spec.Type)
+	}
+	assert.Equal([]reflect.Type{
+		reflect.TypeOf(&chainA{}),
+		reflect.TypeOf((*handler)(nil)).Elem(),
+		reflect.TypeOf(&config{}),
+	}, types)
+}
+
+func provideHandler() handler {
+	var f handlerFunc
+	return f
+}
+
+func TestCheckInterfaceReturns(t *testing.T) {
+	assert := assert.New(t)
+
+	warnings, err := shaft.CheckInterfaceReturns(
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideHandler),
+		shaft.Provide(provideHandlerX),
+		shaft.Invoke(func(*chainA, handler, []handler) {}),
+	)
+	assert.NoError(err)
+	assert.Len(warnings, 2)
+	assert.Contains(warnings[0], "provideHandler)")
+	assert.Contains(warnings[1], "provideHandlerX")
+	for _, warning := range warnings {
+		assert.Contains(warning, "interface shaft_test.handler")
+	}
+}
+
+func TestProvided(t *testing.T) {
+	assert := assert.New(t)
+
+	executed := false
+	specs, err := shaft.Provided(
+		shaft.Provide(provideChainA),
+		shaft.Provide(func(*chainA) (*chainB, error) {
+			executed = true
+			return &chainB{}, nil
+		}),
+		shaft.Provide(provideHandlerX),
+		shaft.Provide(provideHandlerY),
+		shaft.Named("replica", provideDatabase),
+		shaft.Decorate(func(a *chainA) *chainA { return a }),
+		shaft.Invoke(invokeChainC),
+	)
+	assert.NoError(err)
+	assert.False(executed)
+	assert.Equal([]core.Spec{
+		{Type: reflect.TypeOf(&chainA{})},
+		{Type: reflect.TypeOf(&chainB{})},
+		{Type: reflect.TypeOf([]handler(nil)), Group: true},
+		{Type: reflect.TypeOf(&database{}), Name: "replica"},
+	}, specs)
+}
+
+func TestValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(shaft.Validate(
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideHandlerX),
+		shaft.Provide(provideHandlerY),
+		shaft.Provide(decorateChainB),
+		shaft.Default(shaft.Provide(provideChainB)),
+		shaft.Provide(provideChainB),
+	))
+	err := shaft.Validate(
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideChainB),
+		shaft.Provide(provideChainC),
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideChainC),
+	)
+	var duplicates *core.ErrDuplicates
+	assert.ErrorAs(err, &duplicates)
+	assert.Len(duplicates.Conflicts, 2)
+	assert.Equal([]string{
+		"Provide(github.com/aegistudio/shaft_test.provideChainA)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainA)",
+	}, duplicates.Conflicts[0].Nodes)
+	assert.Equal("*shaft_test.chainA", duplicates.Conflicts[0].Type)
+	assert.Equal("*shaft_test.chainC", duplicates.Conflicts[1].Type)
+}
+
+func TestValidateMissing(t *testing.T) {
+	assert := assert.New(t)
+
+	err := shaft.Validate(
+		shaft.Provide(provideChainB),
+		shaft.Provide(func(*chainB, *config) *chainC {
+			return &chainC{}
+		}),
+		shaft.Provide(func(*database) []handler { return nil }),
+		shaft.Provide(func(*tenantStore) *streamReader { return nil }),
+		shaft.Optional(shaft.Invoke(func(*database) {})),
+		shaft.Invoke(invokeChainC),
+		shaft.Invoke(func([]handler, shaft.Provider[*chainA]) {}),
+	)
+	var missing *core.ErrMissing
+	assert.ErrorAs(err, &missing)
+	assert.Equal([]string{
+		"*shaft_test.chainA", "*shaft_test.config", "*shaft_test.database",
+	}, missing.Missing())
+	assert.EqualError(err, "missing dependencies: "+
+		"*shaft_test.chainA, *shaft_test.config, *shaft_test.database")
+}
+
+func TestValidateBuiltins(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := []shaft.Option{
+		shaft.Invoke(func(
+			shaft.Context, *shaft.Lifecycle, *shaft.Warnings,
+		) {
+		}),
+	}
+	assert.NoError(shaft.Validate(opts...))
+	unresolved, err := shaft.Unresolved(opts...)
+	assert.NoError(err)
+	assert.Empty(unresolved)
+	plan, err := shaft.Plan(opts...)
+	assert.NoError(err)
+	assert.NotEmpty(plan)
+	assert.NoError(shaft.Run(opts...))
+}
+
+func decorateDatabase(db *database) (*database, *config) {
+	return db, &config{}
+}
+
+func TestValidateDecorate(t *testing.T) {
+	assert := assert.New(t)
+
+	err := shaft.Validate(
+		shaft.Provide(provideChainA),
+		shaft.Decorate(decorateDatabase),
+	)
+	var decorate *core.ErrDecorate
+	assert.ErrorAs(err, &decorate)
+	assert.Equal(
+		"Decorate(github.com/aegistudio/shaft_test.decorateDatabase)",
+		decorate.Node)
+	assert.Equal("*shaft_test.database", decorate.Type)
+	assert.Equal([]string{"*shaft_test.config"}, decorate.Provides)
+
+	err = shaft.Run(
+		shaft.Decorate(decorateDatabase),
+		shaft.Invoke(func(*config) {}),
+	)
+	assert.ErrorAs(err, &decorate)
+	assert.EqualError(decorate, "node "+
+		"\"Decorate(github.com/aegistudio/shaft_test.decorateDatabase)\" "+
+		"decorates type *shaft_test.database provided by no node, "+
+		"so it cannot provide *shaft_test.config")
+
+	assert.NoError(shaft.Validate(
+		shaft.Provide(provideDatabase),
+		shaft.Decorate(decorateDatabase),
+	))
+}
+
+func TestDependencyPlanned(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := shaft.Compile(
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideChainB),
+		shaft.Invoke(func(*chainB) {}),
+		shaft.Invoke(func(*chainB, *config) {}),
+	)
+	var dependency *core.ErrDependency
+	assert.ErrorAs(err, &dependency)
+	assert.Equal([]string{
+		"Provide(github.com/aegistudio/shaft_test.provideChainA)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainB)",
+		"Invoke(github.com/aegistudio/shaft_test.TestDependencyPlanned.func1)",
+	}, dependency.Planned)
+}
+
+func invokeHandlers([]handler, *chainC) {}
+
+func TestPlan(t *testing.T) {
+	assert := assert.New(t)
+
+	plan, err := shaft.Plan(
+		shaft.Provide(provideChainC),
+		shaft.Provide(provideHandlerY),
+		shaft.Provide(provideChainB),
+		shaft.Provide(redundantObjectC),
+		shaft.Provide(provideHandlerX),
+		shaft.Provide(provideChainA),
+		shaft.Invoke(invokeHandlers),
+	)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"Provide(github.com/aegistudio/shaft_test.provideHandlerY)",
+		"Provide(github.com/aegistudio/shaft_test.provideHandlerX)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainA)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainB)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainC)",
+		"Invoke(github.com/aegistudio/shaft_test.invokeHandlers)",
+	}, plan)
+}
+
+func TestPlanInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	var info shaft.PlanInfo
+	assert.NoError(shaft.Run(
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideChainB),
+		shaft.Provide(redundantObjectC),
+		shaft.Provide(func(info shaft.PlanInfo, _ *chainB) *chainC {
+			return &chainC{}
+		}),
+		shaft.Populate(&info),
+		shaft.Invoke(invokeChainC),
+	))
+	assert.Equal(shaft.PlanInfo{Nodes: 6, Providers: 4}, info)
+}
+
+func TestModuleContract(t *testing.T) {
+	assert := assert.New(t)
+
+	contract := []interface{}{
+		new(*chainB), reflect.TypeOf(&chainC{}), new([]handler),
+	}
+	assert.NoError(shaft.ModuleContract([]shaft.Option{
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideChainB),
+		shaft.Provide(provideChainC),
+		shaft.Provide(provideHandlerX),
+	}, contract...))
+	assert.EqualError(shaft.ModuleContract([]shaft.Option{
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideChainB),
+		shaft.Provide(provideHandlerX),
+	}, contract...), "module does not provide *shaft_test.chainC")
+}
+
+func decorateChainB(b *chainB) *chainB {
+	return b
+}
+
+func TestMermaid(t *testing.T) {
+	assert := assert.New(t)
+
+	result, err := shaft.Mermaid(
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideChainB),
+		shaft.Provide(decorateChainB),
+		shaft.Provide(provideHandlerX),
+		shaft.Invoke(invokeChainC),
+		shaft.Invoke(func(*chainB, []handler) {}),
+	)
+	assert.NoError(err)
+	assert.Equal(`flowchart LR
+    n0["Provide(github.com/aegistudio/shaft_test.provideChainA)"]
+    n1["Provide(github.com/aegistudio/shaft_test.provideChainB)"]
+    n2["Provide(github.com/aegistudio/shaft_test.decorateChainB)"]
+    n3["Provide(github.com/aegistudio/shaft_test.provideHandlerX)"]
+    c0(["Invoke(github.com/aegistudio/shaft_test.invokeChainC)"])
+    c1(["Invoke(github.com/aegistudio/shaft_test.TestMermaid.func1)"])
+    n0 -->|"*shaft_test.chainA"| n1
+    n1 -->|"*shaft_test.chainB"| n2
+    n1 -->|"*shaft_test.chainB"| c1
+    n2 -.->|"*shaft_test.chainB"| c1
+    n3 ==>|"[[]shaft_test.handler]"| c1
+`, result)
+}
+
+func TestExportDOT(t *testing.T) {
+	assert := assert.New(t)
+
+	var b strings.Builder
+	assert.NoError(shaft.ExportDOT(&b,
+		shaft.Provide(provideChainA),
+		shaft.Provide(provideChainB),
+		shaft.Provide(decorateChainB),
+		shaft.Provide(provideHandlerX),
+		shaft.Invoke(func(*chainB, []handler) {}),
+	))
+	assert.Equal(`digraph shaft {
+    n0 [label="Provide(github.com/aegistudio/shaft_test.provideChainA)"];
+    n1 [label="Provide(github.com/aegistudio/shaft_test.provideChainB)"];
+    n2 [label="Provide(github.com/aegistudio/shaft_test.decorateChainB)"];
+    n3 [label="Provide(github.com/aegistudio/shaft_test.provideHandlerX)"];
+    c0 [label="Invoke(github.com/aegistudio/shaft_test.TestExportDOT.func1)", shape=box, style=rounded];
+    n0 -> n1 [label="*shaft_test.chainA"];
+    n1 -> n2 [label="*shaft_test.chainB"];
+    n1 -> c0 [label="*shaft_test.chainB"];
+    n2 -> c0 [label="*shaft_test.chainB", style=dashed];
+    n3 -> c0 [label="[[]shaft_test.handler]", style=bold];
+}
+`, b.String())
+}
@@ -0,0 +1,17 @@
+package shaft
+
+// Keyed wraps T with the tag type K, so that the values of
+// the same type can be distinguished without defining a new
+// type for each of them, e.g. Keyed[MaxConns, int] and
+// Keyed[MinConns, int] are different types to inject.
+//
+// The tag type is only used for distinguishing, and it is
+// conventional to define it as an empty struct.
+type Keyed[K, T any] struct {
+	Value T
+}
+
+// Key wraps the value with the tag type K.
+func Key[K, T any](value T) Keyed[K, T] {
+	return Keyed[K, T]{Value: value}
+}
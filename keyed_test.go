@@ -0,0 +1,34 @@
+package shaft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+type (
+	maxConns struct{}
+	minConns struct{}
+)
+
+func TestKeyed(t *testing.T) {
+	assert := assert.New(t)
+
+	var max, min int
+	assert.NoError(shaft.Run(
+		shaft.Supply(shaft.Key[maxConns](16)),
+		shaft.Provide(func() shaft.Keyed[minConns, int] {
+			return shaft.Key[minConns](4)
+		}),
+		shaft.Invoke(func(
+			maxValue shaft.Keyed[maxConns, int],
+			minValue shaft.Keyed[minConns, int],
+		) {
+			max, min = maxValue.Value, minValue.Value
+		}),
+	))
+	assert.Equal(16, max)
+	assert.Equal(4, min)
+}
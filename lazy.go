@@ -0,0 +1,91 @@
+package shaft
+
+import (
+	"reflect"
+
+	"github.com/aegistudio/shaft/core"
+)
+
+// Provider is a lazy accessor of T.
+//
+// Depending on Provider[T] does not require T to be
+// constructed before the consumer, instead T is scheduled
+// after the consumer if it has not been, and the accessor
+// returns an error if T has not been constructed yet when
+// it is called. This is useful for breaking a legitimate
+// cyclic dependency, where one side resolves the other
+// side after the construction.
+type Provider[T any] func() (T, error)
+
+// lazyPort is implemented by the lazy accessors so that
+// they can be recognized and converted from core.Lazy.
+type lazyPort interface {
+	lazyType() reflect.Type
+	fromLazy(core.Lazy) reflect.Value
+}
+
+var typeLazyPort = reflect.TypeOf((*lazyPort)(nil)).Elem()
+
+func (Provider[T]) lazyType() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+func (Provider[T]) fromLazy(lazy core.Lazy) reflect.Value {
+	return reflect.ValueOf(Provider[T](func() (T, error) {
+		var result T
+		value, err := lazy()
+		if err != nil {
+			return result, err
+		}
+		reflect.ValueOf(&result).Elem().Set(value)
+		return result, nil
+	}))
+}
+
+// convertLazy creates the function converting the core.Lazy
+// arguments into the lazy accessors requested by function.
+func convertLazy(args []reflect.Type) func([]reflect.Value) []reflect.Value {
+	var indices []int
+	for i, arg := range args {
+		if arg.Implements(typeLazyPort) {
+			indices = append(indices, i)
+		}
+	}
+	return func(in []reflect.Value) []reflect.Value {
+		if len(indices) == 0 {
+			return in
+		}
+		in = append([]reflect.Value(nil), in...)
+		for _, i := range indices {
+			port := reflect.Zero(args[i]).Interface().(lazyPort)
+			in[i] = port.fromLazy(in[i].Interface().(core.Lazy))
+		}
+		return in
+	}
+}
+
+// Resolver resolves the values of the types computed at
+// runtime, which is useful for dynamic dispatchers built
+// upon the framework, and is supplied by Run.
+//
+// Just like Provider[T], only the values constructed in the
+// execution plan can be resolved, and the resolver does not
+// cause any type to be constructed.
+type Resolver interface {
+	Resolve(reflect.Type) (reflect.Value, error)
+}
+
+type resolver core.Resolve
+
+func (r resolver) Resolve(typ reflect.Type) (reflect.Value, error) {
+	return r(convertSingle(typ))
+}
+
+func supplyResolver() Option {
+	typ := reflect.TypeOf((*Resolver)(nil)).Elem()
+	return core.SupplyResolver(func(resolve core.Resolve) reflect.Value {
+		return reflect.ValueOf(resolver(resolve))
+	}, convertSingle(typ), valuesOp{
+		op: opSupply, types: []reflect.Type{typ},
+	})
+}
@@ -0,0 +1,120 @@
+package shaft_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+	"github.com/aegistudio/shaft/core"
+)
+
+type cyclicA struct {
+	b *cyclicB
+}
+
+type cyclicB struct {
+	a shaft.Provider[*cyclicA]
+}
+
+func TestLazyProvider(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.Provide(func(events *[]string, b *cyclicB) *cyclicA {
+			*events = append(*events, "provide a")
+			return &cyclicA{b: b}
+		}),
+		shaft.Provide(func(
+			events *[]string, a shaft.Provider[*cyclicA],
+		) (*cyclicB, error) {
+			*events = append(*events, "provide b")
+			_, err := a()
+			assert.Error(err)
+			return &cyclicB{a: a}, nil
+		}),
+		shaft.Invoke(func(events *[]string, a *cyclicA) error {
+			*events = append(*events, "invoke")
+			resolved, err := a.b.a()
+			assert.Same(a, resolved)
+			return err
+		}),
+	))
+	assert.Equal([]string{
+		"provide b", "provide a", "invoke",
+	}, events)
+}
+
+func TestLazyProviderDeferred(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.Provide(redundantObjectC),
+		shaft.Invoke(func(events *[]string, c shaft.Provider[*C]) {
+			*events = append(*events, "invoke")
+			_, err := c()
+			assert.EqualError(err,
+				"type *shaft_test.C has not been constructed yet")
+		}),
+	))
+	assert.Equal([]string{"invoke", "provide c"}, events)
+}
+
+type dispatcher func(reflect.Type) (reflect.Value, error)
+
+func TestResolver(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	var c *C
+	var dispatch dispatcher
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.Provide(redundantObjectC),
+		shaft.Provide(func(r shaft.Resolver) dispatcher {
+			return r.Resolve
+		}),
+		shaft.Populate(&c, &dispatch),
+	))
+	value, err := dispatch(reflect.TypeOf(&C{}))
+	assert.NoError(err)
+	assert.Same(c, value.Interface())
+	_, err = dispatch(reflect.TypeOf(&D{}))
+	assert.EqualError(err, "type *shaft_test.D is not scheduled")
+}
+
+func provideCyclicA(*cyclicB) *cyclicA {
+	return &cyclicA{}
+}
+
+func provideCyclicB(*C, *cyclicA) *cyclicB {
+	return &cyclicB{}
+}
+
+func TestCyclicDependency(t *testing.T) {
+	assert := assert.New(t)
+
+	err := shaft.Run(
+		shaft.Provide(provideCyclicA),
+		shaft.Provide(provideCyclicB),
+		shaft.Provide(redundantObjectC),
+		shaft.Supply(new([]string)),
+		shaft.Invoke(func(*cyclicA) {}),
+	)
+	var cycle *core.ErrCycle
+	assert.ErrorAs(err, &cycle)
+	assert.Equal([]string{
+		"Provide(github.com/aegistudio/shaft_test.provideCyclicA)",
+		"Provide(github.com/aegistudio/shaft_test.provideCyclicB)",
+		"Provide(github.com/aegistudio/shaft_test.provideCyclicA)",
+	}, cycle.Nodes)
+	assert.EqualError(cycle, "cyclic dependency "+
+		"Provide(github.com/aegistudio/shaft_test.provideCyclicA) -> "+
+		"Provide(github.com/aegistudio/shaft_test.provideCyclicB) -> "+
+		"Provide(github.com/aegistudio/shaft_test.provideCyclicA)")
+}
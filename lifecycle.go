@@ -0,0 +1,108 @@
+package shaft
+
+import (
+	"sort"
+	"sync"
+)
+
+// Lifecycle collects the stop functions registered by the
+// providers, which is simpler than Stack when a constructed
+// resource just needs to be closed, e.g.
+//
+//	func(lc *shaft.Lifecycle) (*os.File, error) {
+//		f, err := os.Open("data")
+//		if err != nil {
+//			return nil, err
+//		}
+//		lc.OnStop(f.Close)
+//		return f, nil
+//	}
+//
+// The *Lifecycle is supplied by Run, which calls all of the
+// stop functions after the remainder of the execution plan has
+// been executed, no matter whether it succeeds, and returns the
+// first error of them if the execution plan succeeds.
+//
+// The stop functions are called in the reverse order of the
+// nodes registering them in the execution plan, and those of
+// the same node in the reverse order of registration, so the
+// order is the same even if the nodes are executed in parallel
+// by WithConcurrency.
+type Lifecycle struct {
+	// root is the lifecycle collecting the stop functions, or
+	// nil if it is the root itself.
+	root     *Lifecycle
+	position int
+
+	mu    sync.Mutex
+	stops []lifecycleStop
+}
+
+// lifecycleStop is the stop function registered by the node at
+// the position, or the position is -1 if it is registered with
+// the root, which is called before the others.
+type lifecycleStop struct {
+	position int
+	f        func() error
+}
+
+// ScopeNode attributes the stop functions registered through
+// the returned lifecycle to the node at the position, see also
+// core.NodeScoped.
+func (l *Lifecycle) ScopeNode(position int) interface{} {
+	return &Lifecycle{root: l.rootOf(), position: position}
+}
+
+func (l *Lifecycle) rootOf() *Lifecycle {
+	if l.root != nil {
+		return l.root
+	}
+	return l
+}
+
+// OnStop registers the function to call while stopping.
+func (l *Lifecycle) OnStop(f func() error) {
+	position := -1
+	if l.root != nil {
+		position = l.position
+	}
+	root := l.rootOf()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	root.stops = append(root.stops, lifecycleStop{
+		position: position, f: f,
+	})
+}
+
+// stop calls the stop functions in the reverse order, and
+// returns the first error of them.
+func (l *Lifecycle) stop() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stops := make([]lifecycleStop, 0, len(l.stops))
+	for i := len(l.stops) - 1; i >= 0; i-- {
+		stops = append(stops, l.stops[i])
+	}
+	sort.SliceStable(stops, func(i, j int) bool {
+		if stops[i].position < 0 || stops[j].position < 0 {
+			return stops[i].position < 0 && stops[j].position >= 0
+		}
+		return stops[i].position > stops[j].position
+	})
+	var result error
+	for _, stop := range stops {
+		if err := stop.f(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+func stackLifecycle(f func(*Lifecycle) error) error {
+	lifecycle := &Lifecycle{}
+	err := f(lifecycle)
+	if stopErr := lifecycle.stop(); err == nil {
+		err = stopErr
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+package shaft_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+func TestLifecycle(t *testing.T) {
+	assert := assert.New(t)
+
+	errStop := errors.New("stop failed")
+	errInvoke := errors.New("invoke failed")
+	var events []string
+	opts := func(err error) []shaft.Option {
+		events = nil
+		return []shaft.Option{
+			shaft.Supply(&events),
+			shaft.Provide(func(
+				lc *shaft.Lifecycle, events *[]string,
+			) *chainA {
+				lc.OnStop(func() error {
+					*events = append(*events, "stop a")
+					return errStop
+				})
+				return &chainA{}
+			}),
+			shaft.Provide(func(
+				lc *shaft.Lifecycle, events *[]string, _ *chainA,
+			) *chainB {
+				lc.OnStop(func() error {
+					*events = append(*events, "stop b")
+					return nil
+				})
+				return &chainB{}
+			}),
+			shaft.Invoke(func(events *[]string, _ *chainB) error {
+				*events = append(*events, "invoke")
+				return err
+			}),
+		}
+	}
+	assert.ErrorIs(shaft.Run(opts(nil)...), errStop)
+	assert.Equal([]string{"invoke", "stop b", "stop a"}, events)
+	assert.ErrorIs(shaft.Run(opts(errInvoke)...), errInvoke)
+	assert.Equal([]string{"invoke", "stop b", "stop a"}, events)
+}
+
+func TestLifecycleConcurrency(t *testing.T) {
+	assert := assert.New(t)
+
+	// The nodes executed later register their stop functions
+	// earlier, but they are still stopped by the plan order.
+	var events []string
+	provide := func(name plugin, d time.Duration) shaft.Option {
+		return shaft.Provide(func(lc *shaft.Lifecycle) []plugin {
+			time.Sleep(d)
+			lc.OnStop(func() error {
+				events = append(events, "stop "+string(name))
+				return nil
+			})
+			return []plugin{name}
+		})
+	}
+	assert.NoError(shaft.Run(
+		shaft.WithConcurrency(4),
+		provide("a", 40*time.Millisecond),
+		provide("b", 30*time.Millisecond),
+		provide("c", 20*time.Millisecond),
+		provide("d", 10*time.Millisecond),
+		shaft.Invoke(func([]plugin) {}),
+	))
+	assert.Equal([]string{"stop d", "stop c", "stop b", "stop a"}, events)
+}
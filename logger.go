@@ -0,0 +1,70 @@
+package shaft
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+)
+
+// LogWriter is the writer which all the logs are written to,
+// so that the logs can be routed uniformly.
+//
+// Run provides os.Stderr as the default LogWriter, and the
+// *log.Logger writing to the LogWriter as the default logger,
+// both of them can be overridden by Supply.
+type LogWriter struct {
+	io.Writer
+}
+
+func provideLogWriter() LogWriter {
+	return LogWriter{Writer: os.Stderr}
+}
+
+func provideLogger(w LogWriter) *log.Logger {
+	return log.New(w, "", log.LstdFlags)
+}
+
+// ScopedLogger is a logger tagged with the name of the node
+// which it is injected into, so that the lines logged by the
+// nodes are attributable without tagging them manually.
+//
+// Depending on ScopedLogger depends on the *log.Logger, and
+// the injected logger writes to the same writer with the same
+// flags, but with the name of the node appended to the prefix.
+type ScopedLogger struct {
+	*log.Logger
+}
+
+var (
+	typeScopedLogger = reflect.TypeOf(ScopedLogger{})
+	typeLogger       = reflect.TypeOf((*log.Logger)(nil))
+)
+
+// convertScoped creates the function converting the loggers
+// into the scoped loggers requested by the node.
+func convertScoped(
+	args []reflect.Type, format fmt.Stringer,
+) func([]reflect.Value) []reflect.Value {
+	var indices []int
+	for i, arg := range args {
+		if arg == typeScopedLogger {
+			indices = append(indices, i)
+		}
+	}
+	return func(in []reflect.Value) []reflect.Value {
+		if len(indices) == 0 {
+			return in
+		}
+		in = append([]reflect.Value(nil), in...)
+		for _, i := range indices {
+			base := in[i].Interface().(*log.Logger)
+			in[i] = reflect.ValueOf(ScopedLogger{Logger: log.New(
+				base.Writer(), fmt.Sprintf("%s%s: ", base.Prefix(), format),
+				base.Flags(),
+			)})
+		}
+		return in
+	}
+}
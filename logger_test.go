@@ -0,0 +1,58 @@
+package shaft_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+func provideScopedB(logger shaft.ScopedLogger) *B {
+	logger.Print("provide b")
+	return &B{}
+}
+
+func provideScopedC(logger shaft.ScopedLogger, _ *B) *C {
+	logger.Print("provide c")
+	return &C{}
+}
+
+func TestScopedLogger(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(shaft.Run(
+		shaft.Supply(log.New(&buf, "app: ", 0)),
+		shaft.Provide(provideScopedB),
+		shaft.Provide(provideScopedC),
+		shaft.Invoke(func(*C) {}),
+	))
+	assert.Equal(
+		"app: Provide(github.com/aegistudio/shaft_test.provideScopedB): provide b\n"+
+			"app: Provide(github.com/aegistudio/shaft_test.provideScopedC): provide c\n",
+		buf.String())
+}
+
+func TestLogWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	assert.NoError(shaft.Run(
+		shaft.Supply(shaft.LogWriter{Writer: &buf}),
+		shaft.Provide(provideScopedB),
+		shaft.Invoke(func(logger *log.Logger, _ *B) {
+			logger.SetFlags(0)
+			logger.Print("invoke")
+		}),
+	))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(lines, 2)
+	assert.True(strings.HasPrefix(lines[0],
+		"Provide(github.com/aegistudio/shaft_test.provideScopedB): "))
+	assert.True(strings.HasSuffix(lines[0], "provide b"))
+	assert.Equal("invoke", lines[1])
+}
@@ -18,22 +18,231 @@
 //      called only after someone providing this type.
 //   3. Because you can assign a name to type easily by defining
 //      `type Name T`, and we would like to keep it as simple as
-//      possible, we don't provide naming support here.
+//      possible, the types are not named by default. When the
+//      wrapper types are tedious, the values can be provided
+//      and consumed under a name by Named and UseNamed.
 package shaft
 
 import (
+	"context"
+	"time"
+
 	"github.com/aegistudio/shaft/core"
 )
 
 // Option is just a simple forwarding of core.Option.
 type Option = core.Option
 
-// Run is just a simple forwarding of core.Run.
+// ProviderInfo is just a simple forwarding of core.ProviderInfo.
+type ProviderInfo = core.ProviderInfo
+
+// Program is just a simple forwarding of core.Program.
+type Program = core.Program
+
+// Compile compiles the options into a core.Program, which is
+// seeded with the same nodes supplied by RunWithResult, just
+// like NewContainer. The StartupDuration is measured since the
+// program is compiled.
+func Compile(opts ...Option) (*Program, error) {
+	return core.Compile(
+		builtins(time.Now(), &Warnings{}), Module(opts...))
+}
+
+// Container is just a simple forwarding of core.Container.
+type Container = core.Container
+
+// NewContainer creates a core.Container with the options, and
+// the container is seeded with the same nodes supplied by
+// RunWithResult, so that the options work in the container
+// just like in Run. The StartupDuration is measured since the
+// container is created.
+func NewContainer(opts ...Option) *Container {
+	return core.NewContainer(
+		builtins(time.Now(), &Warnings{}), Module(opts...))
+}
+
+// BuildStats is just a simple forwarding of core.BuildStats.
+type BuildStats = core.BuildStats
+
+// BenchmarkBuild is just a simple forwarding of core.BenchmarkBuild.
+func BenchmarkBuild(opts ...Option) (BuildStats, error) {
+	return core.BenchmarkBuild(opts...)
+}
+
+// MaxConcurrency is just a simple forwarding of
+// core.MaxConcurrency.
+func MaxConcurrency(n int) Option {
+	return core.MaxConcurrency(n)
+}
+
+// PlanInfo is just a simple forwarding of core.PlanInfo.
+type PlanInfo = core.PlanInfo
+
+// Warnings is just a simple forwarding of core.Warnings.
+type Warnings = core.Warnings
+
+// RunResult is the result of the dependency injection.
+type RunResult struct {
+	// Warnings are the warnings emitted while building and
+	// executing the execution plan.
+	Warnings []string
+}
+
+// RunWithResult performs the dependency injection and returns
+// the result alongside with the error.
+//
+// A *Warnings is supplied so that the providers can emit non
+// fatal warnings into it, and they are returned in the result.
+// A *ErrorGroup is also supplied for background goroutines,
+// *Lifecycle for the functions to call while stopping, PlanInfo
+// for the information of the execution plan, Context canceled
+// on failure, and StartupDuration for the time elapsed since
+// the call.
+func RunWithResult(opts ...Option) (RunResult, error) {
+	return RunWithResultContext(context.Background(), opts...)
+}
+
+// RunWithResultContext is like RunWithResult, but stops
+// executing the remaining nodes once the context is done, see
+// also core.RunContext.
+func RunWithResultContext(
+	ctx context.Context, opts ...Option,
+) (RunResult, error) {
+	start := time.Now()
+	warnings := &Warnings{}
+	err := core.RunContext(ctx,
+		builtins(start, warnings), Module(opts...))
+	return RunResult{Warnings: warnings.List()}, err
+}
+
+// builtins is the nodes supplied by the framework to the user
+// options, see also RunWithResult.
+func builtins(start time.Time, warnings *Warnings) Option {
+	return core.Module(core.WithWarnings(warnings), core.Builtin(
+		Supply(warnings), Stack(stackErrorGroup), Stack(stackLifecycle),
+		supplyPlanInfo(), supplyResolver(), supplyContext(),
+		provideStartupDuration(start), core.Default(
+			convertProvider(provideLogWriter).builtin().option(opProvide),
+			convertProvider(provideLogger).builtin().option(opProvide),
+		),
+	))
+}
+
+// Run performs the dependency injection and ignores the result.
 func Run(opts ...Option) error {
-	return core.Run(opts...)
+	_, err := RunWithResult(opts...)
+	return err
+}
+
+// RunContext performs the dependency injection with the
+// context and ignores the result.
+func RunContext(ctx context.Context, opts ...Option) error {
+	_, err := RunWithResultContext(ctx, opts...)
+	return err
 }
 
 // Module is just a simple forwarding of core.Module.
 func Module(opts ...Option) Option {
 	return core.Module(opts...)
 }
+
+// NamedModule is just a simple forwarding of core.NamedModule.
+func NamedModule(name string, opts ...Option) Option {
+	return core.NamedModule(name, opts...)
+}
+
+// OneOf is just a simple forwarding of core.OneOf.
+func OneOf(opts ...Option) Option {
+	return core.OneOf(opts...)
+}
+
+// Once is just a simple forwarding of core.Once.
+func Once(key string, opts ...Option) Option {
+	return core.Once(key, opts...)
+}
+
+// AssignableMatching is just a simple forwarding of
+// core.AssignableMatching.
+func AssignableMatching() Option {
+	return core.AssignableMatching()
+}
+
+// InvokeDependencyOrder is just a simple forwarding of
+// core.InvokeDependencyOrder.
+func InvokeDependencyOrder() Option {
+	return core.InvokeDependencyOrder()
+}
+
+// NoImplicitDecorate is just a simple forwarding of
+// core.NoImplicitDecorate.
+func NoImplicitDecorate() Option {
+	return core.NoImplicitDecorate()
+}
+
+// WithUnusedReporter is just a simple forwarding of
+// core.WithUnusedReporter.
+func WithUnusedReporter(f func([]string)) Option {
+	return core.WithUnusedReporter(f)
+}
+
+// RequireErrorReturns rejects the constructors never returning
+// an error, see also core.RequireErrorReturns.
+//
+// The nodes constructed by the framework are exempt, including
+// those wrapping the functions of Partitioned and
+// ProvideMapAsGroup, whose signatures cannot return an error.
+func RequireErrorReturns() Option {
+	return core.RequireErrorReturns()
+}
+
+// WithConcurrency is just a simple forwarding of
+// core.WithConcurrency.
+func WithConcurrency(n int) Option {
+	return core.WithConcurrency(n)
+}
+
+// Observer is just a simple forwarding of core.Observer.
+type Observer = core.Observer
+
+// WithObserver is just a simple forwarding of core.WithObserver.
+func WithObserver(obs Observer) Option {
+	return core.WithObserver(obs)
+}
+
+// RunStats is just a simple forwarding of core.RunStats.
+type RunStats = core.RunStats
+
+// WithStats is just a simple forwarding of core.WithStats.
+func WithStats(stats *RunStats) Option {
+	return core.WithStats(stats)
+}
+
+// RunWithStats performs the dependency injection just like
+// Run, and returns the statistics of the execution, see also
+// core.RunWithStats.
+func RunWithStats(opts ...Option) (RunStats, error) {
+	stats := RunStats{}
+	err := Run(Module(opts...), WithStats(&stats))
+	return stats, err
+}
+
+// Sequential is just a simple forwarding of core.Sequential.
+func Sequential(opts ...Option) Option {
+	return core.Sequential(opts...)
+}
+
+// Optional is just a simple forwarding of core.Optional.
+func Optional(opts ...Option) Option {
+	return core.Optional(opts...)
+}
+
+// Default is just a simple forwarding of core.Default.
+func Default(opts ...Option) Option {
+	return core.Default(opts...)
+}
+
+// NoSupplyShadow is just a simple forwarding of
+// core.NoSupplyShadow.
+func NoSupplyShadow() Option {
+	return core.NoSupplyShadow()
+}
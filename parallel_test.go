@@ -0,0 +1,119 @@
+package shaft_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+	"github.com/aegistudio/shaft/core"
+)
+
+// concurrencyCounter records the maximum number of the
+// functions running at the same time.
+type concurrencyCounter struct {
+	running, max int32
+}
+
+func (c *concurrencyCounter) enter() {
+	running := atomic.AddInt32(&c.running, 1)
+	for {
+		max := atomic.LoadInt32(&c.max)
+		if running <= max || atomic.CompareAndSwapInt32(
+			&c.max, max, running) {
+			return
+		}
+	}
+}
+
+func (c *concurrencyCounter) leave() {
+	atomic.AddInt32(&c.running, -1)
+}
+
+func (c *concurrencyCounter) provide(name plugin) shaft.Option {
+	return shaft.Provide(func() []plugin {
+		c.enter()
+		defer c.leave()
+		time.Sleep(20 * time.Millisecond)
+		return []plugin{name}
+	})
+}
+
+func TestWithConcurrency(t *testing.T) {
+	assert := assert.New(t)
+
+	counter := &concurrencyCounter{}
+	var plugins []plugin
+	assert.NoError(shaft.Run(
+		shaft.WithConcurrency(2),
+		counter.provide("a"), counter.provide("b"),
+		counter.provide("c"), counter.provide("d"),
+		counter.provide("e"), counter.provide("f"),
+		shaft.Populate(&plugins),
+	))
+	assert.Equal(int32(2), counter.max)
+	assert.Equal([]plugin{"a", "b", "c", "d", "e", "f"}, plugins)
+
+	counter = &concurrencyCounter{}
+	plugins = nil
+	assert.NoError(shaft.Run(
+		counter.provide("a"), counter.provide("b"),
+		shaft.Populate(&plugins),
+	))
+	assert.Equal(int32(1), counter.max)
+	assert.Equal([]plugin{"a", "b"}, plugins)
+}
+
+func TestWithConcurrencyError(t *testing.T) {
+	assert := assert.New(t)
+
+	errProvide := errors.New("provide failed")
+	var started int32
+	provide := func(err error, delay time.Duration) shaft.Option {
+		return shaft.Provide(func() ([]plugin, error) {
+			atomic.AddInt32(&started, 1)
+			time.Sleep(delay)
+			return nil, err
+		})
+	}
+	err := shaft.Run(
+		shaft.WithConcurrency(2),
+		provide(errProvide, 10*time.Millisecond),
+		provide(nil, 50*time.Millisecond),
+		provide(nil, 0), provide(nil, 0),
+		shaft.Invoke(func([]plugin) {}),
+	)
+	assert.ErrorIs(err, errProvide)
+	var execErr *core.ErrExecute
+	assert.ErrorAs(err, &execErr)
+	assert.Equal(int32(2), atomic.LoadInt32(&started))
+}
+
+func TestMaxConcurrency(t *testing.T) {
+	assert := assert.New(t)
+
+	counter := &concurrencyCounter{}
+	var plugins []plugin
+	assert.NoError(shaft.Run(
+		shaft.WithConcurrency(8), shaft.MaxConcurrency(3),
+		counter.provide("a"), counter.provide("b"),
+		counter.provide("c"), counter.provide("d"),
+		counter.provide("e"), counter.provide("f"),
+		counter.provide("g"), counter.provide("h"),
+		shaft.Populate(&plugins),
+	))
+	assert.Equal(int32(3), atomic.LoadInt32(&counter.max))
+	assert.Len(plugins, 8)
+
+	counter = &concurrencyCounter{}
+	plugins = nil
+	assert.NoError(shaft.Run(
+		shaft.MaxConcurrency(3),
+		counter.provide("a"), counter.provide("b"),
+		shaft.Populate(&plugins),
+	))
+	assert.Equal(int32(1), atomic.LoadInt32(&counter.max))
+}
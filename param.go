@@ -0,0 +1,236 @@
+package shaft
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// In is embedded into a struct to declare it as a parameter
+// object, so that a function with many dependencies can accept
+// them in a single struct instead of a long argument list.
+//
+// The exported fields of the parameter object are consumed
+// individually as if they were the arguments, and the struct
+// is filled with them before calling the function. The fields
+// can be tagged to modify how they are consumed:
+//
+//	type params struct {
+//		shaft.In
+//		Primary  *sql.DB
+//		Replica  *sql.DB     `shaft:"name=replica"`
+//		Cache    *Cache      `shaft:"optional"`
+//		Handlers []Handler   `shaft:"group"`
+//	}
+//
+// Where the name consumes the value provided by Named, the
+// optional injects the zero value when the type is missing
+// just like Optional, and the group declares the slice field
+// as a group explicitly, which is also the default.
+type In struct{}
+
+var typeIn = reflect.TypeOf(In{})
+
+// paramTag is the modifiers of a field of parameter object.
+type paramTag struct {
+	name     string
+	optional bool
+}
+
+func parseParamTag(field reflect.StructField) paramTag {
+	var result paramTag
+	tag, ok := field.Tag.Lookup("shaft")
+	if !ok || tag == "" {
+		return result
+	}
+	for _, item := range strings.Split(tag, ",") {
+		switch {
+		case item == "optional":
+			result.optional = true
+		case item == "group":
+			if field.Type.Kind() != reflect.Slice {
+				panic(fmt.Sprintf(
+					"non-slice field %s cannot be a group", field.Name))
+			}
+		case strings.HasPrefix(item, "name="):
+			result.name = strings.TrimPrefix(item, "name=")
+		default:
+			panic(fmt.Sprintf(
+				"invalid tag %q of field %s", item, field.Name))
+		}
+	}
+	return result
+}
+
+func isParamObject(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.Anonymous && field.Type == typeIn {
+			return true
+		}
+	}
+	return false
+}
+
+// paramObject records the fields of a parameter object that
+// are expanded into the arguments.
+type paramObject struct {
+	typ    reflect.Type
+	fields []int
+}
+
+// expandParams expands the parameter objects in the arguments
+// into their fields, and returns the function packing the
+// expanded values back into the arguments.
+func expandParams(args []reflect.Type) (
+	[]reflect.Type, []paramTag, func([]reflect.Value) []reflect.Value,
+) {
+	var expanded []reflect.Type
+	var tags []paramTag
+	objects := make([]*paramObject, len(args))
+	found := false
+	for i, arg := range args {
+		if !isParamObject(arg) {
+			expanded = append(expanded, arg)
+			tags = append(tags, paramTag{})
+			continue
+		}
+		found = true
+		object := &paramObject{typ: arg}
+		for j := 0; j < arg.NumField(); j++ {
+			field := arg.Field(j)
+			if field.PkgPath != "" || (field.Anonymous && field.Type == typeIn) {
+				continue
+			}
+			object.fields = append(object.fields, j)
+			expanded = append(expanded, field.Type)
+			tags = append(tags, parseParamTag(field))
+		}
+		objects[i] = object
+	}
+	if !found {
+		return args, nil, func(in []reflect.Value) []reflect.Value {
+			return in
+		}
+	}
+	return expanded, tags, func(in []reflect.Value) []reflect.Value {
+		result := make([]reflect.Value, 0, len(objects))
+		for _, object := range objects {
+			if object == nil {
+				result = append(result, in[0])
+				in = in[1:]
+				continue
+			}
+			value := reflect.New(object.typ).Elem()
+			for _, j := range object.fields {
+				value.Field(j).Set(in[0])
+				in = in[1:]
+			}
+			result = append(result, value)
+		}
+		return result
+	}
+}
+
+// Out is embedded into a struct to declare it as a result
+// object, so that a function can provide related values in a
+// single struct instead of a long result list.
+//
+// The exported fields of the result object are provided
+// individually as if they were the results. The fields can be
+// tagged with the name and group just like the fields of the
+// parameter object, and the embedded result objects are also
+// expanded, so that the result objects can be composed:
+//
+//	type streams struct {
+//		shaft.Out
+//		Reader   *Reader
+//		Writer   *Writer `shaft:"name=stream"`
+//		Handlers []Handler
+//	}
+type Out struct{}
+
+var typeOut = reflect.TypeOf(Out{})
+
+func isResultObject(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.Anonymous && field.Type == typeOut {
+			return true
+		}
+	}
+	return false
+}
+
+// resultFields collects the paths of the fields of the result
+// object, expanding the embedded result objects recursively.
+func resultFields(
+	typ reflect.Type, prefix []int,
+	paths *[][]int, rets *[]reflect.Type, tags *[]paramTag,
+) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.Anonymous && field.Type == typeOut {
+			continue
+		}
+		path := append(append([]int(nil), prefix...), i)
+		if field.Anonymous && isResultObject(field.Type) {
+			resultFields(field.Type, path, paths, rets, tags)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := parseParamTag(field)
+		if tag.optional {
+			panic(fmt.Sprintf(
+				"result field %s cannot be optional", field.Name))
+		}
+		*paths = append(*paths, path)
+		*rets = append(*rets, field.Type)
+		*tags = append(*tags, tag)
+	}
+}
+
+// expandResults expands the result objects in the results
+// into their fields, and returns the function unpacking the
+// results into the expanded values.
+func expandResults(rets []reflect.Type) (
+	[]reflect.Type, []paramTag, func([]reflect.Value) []reflect.Value,
+) {
+	var expanded []reflect.Type
+	var tags []paramTag
+	objects := make([][][]int, len(rets))
+	found := false
+	for i, ret := range rets {
+		if !isResultObject(ret) {
+			expanded = append(expanded, ret)
+			tags = append(tags, paramTag{})
+			continue
+		}
+		found = true
+		resultFields(ret, nil, &objects[i], &expanded, &tags)
+	}
+	if !found {
+		return rets, nil, func(out []reflect.Value) []reflect.Value {
+			return out
+		}
+	}
+	return expanded, tags, func(out []reflect.Value) []reflect.Value {
+		result := make([]reflect.Value, 0, len(expanded))
+		for i, value := range out {
+			if !isResultObject(rets[i]) {
+				result = append(result, value)
+				continue
+			}
+			for _, path := range objects[i] {
+				result = append(result, value.FieldByIndex(path))
+			}
+		}
+		return result
+	}
+}
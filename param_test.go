@@ -0,0 +1,116 @@
+package shaft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+type databaseParams struct {
+	shaft.In
+	Primary  *database
+	Replica  *database `shaft:"name=replica"`
+	Config   *config   `shaft:"optional"`
+	Handlers []handler `shaft:"group"`
+	ignore   *database
+}
+
+type databasePair struct {
+	primary, replica string
+}
+
+func TestParamObject(t *testing.T) {
+	assert := assert.New(t)
+
+	var pair *databasePair
+	var params databaseParams
+	assert.NoError(shaft.Run(
+		shaft.Provide(func() *database {
+			return &database{addr: "primary"}
+		}),
+		shaft.Named("replica", func() *database {
+			return &database{addr: "replica"}
+		}),
+		shaft.Provide(provideHandlerX),
+		shaft.Provide(provideHandlerY),
+		shaft.Provide(func(p databaseParams) *databasePair {
+			return &databasePair{
+				primary: p.Primary.addr, replica: p.Replica.addr,
+			}
+		}),
+		shaft.Invoke(func(p *databasePair, q databaseParams) {
+			pair, params = p, q
+		}),
+	))
+	assert.Equal(&databasePair{primary: "primary", replica: "replica"}, pair)
+	assert.Nil(params.Config)
+	assert.Len(params.Handlers, 2)
+	assert.Nil(params.ignore)
+
+	assert.Panics(func() {
+		shaft.Invoke(func(struct {
+			shaft.In
+			Primary *database `shaft:"group"`
+		}) {
+		})
+	})
+}
+
+type streamReader struct{}
+
+type streamWriter struct {
+	name string
+}
+
+type streamBundle struct {
+	shaft.Out
+	Reader *streamReader
+	Writer *streamWriter `shaft:"name=stream"`
+}
+
+type streamResults struct {
+	shaft.Out
+	streamBundle
+	Handlers []handler `shaft:"group"`
+	ignore   *streamReader
+}
+
+func TestResultObject(t *testing.T) {
+	assert := assert.New(t)
+
+	var reader *streamReader
+	var writer *streamWriter
+	var handlers []handler
+	assert.NoError(shaft.Run(
+		shaft.Provide(provideHandlerX),
+		shaft.Provide(func() streamResults {
+			return streamResults{
+				streamBundle: streamBundle{
+					Reader: &streamReader{},
+					Writer: &streamWriter{name: "stream"},
+				},
+				Handlers: provideHandlerY(),
+			}
+		}),
+		shaft.Populate(&reader, &handlers),
+		shaft.UseNamed("stream", new(*streamWriter),
+			shaft.Populate(&writer)),
+	))
+	assert.NotNil(reader)
+	assert.Equal("stream", writer.name)
+	assert.Len(handlers, 2)
+
+	assert.Panics(func() {
+		shaft.Provide(func() struct {
+			shaft.Out
+			Reader *streamReader `shaft:"optional"`
+		} {
+			return struct {
+				shaft.Out
+				Reader *streamReader `shaft:"optional"`
+			}{}
+		})
+	})
+}
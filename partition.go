@@ -0,0 +1,46 @@
+package shaft
+
+import (
+	"sync"
+)
+
+// Partition is the accessor of T partitioned by the key K,
+// e.g. a tenant, which is provided by Partitioned[K, T].
+//
+// The value of each key is constructed on its first access,
+// and is cached for the subsequent accesses with the key.
+type Partition[K comparable, T any] struct {
+	f      func(K) T
+	mu     sync.Mutex
+	values map[K]T
+}
+
+// Get returns the value of T for the key, constructing it if
+// it has not been constructed yet.
+func (p *Partition[K, T]) Get(key K) T {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if value, ok := p.values[key]; ok {
+		return value
+	}
+	value := p.f(key)
+	p.values[key] = value
+	return value
+}
+
+// Partitioned provides *Partition[K, T] constructing T with the
+// function for each of the keys, where the keys are known only
+// at runtime, e.g. the tenants of a multi-tenant server. It is
+// like Named, but the values are keyed dynamically.
+//
+// The values are cached in the partition, so each execution
+// of the plan constructs the values independently.
+//
+// The partition is constructed by the framework, so it is
+// exempt from RequireErrorReturns, although f cannot return
+// an error.
+func Partitioned[K comparable, T any](f func(K) T) Option {
+	return convertProvider(func() *Partition[K, T] {
+		return &Partition[K, T]{f: f, values: make(map[K]T)}
+	}).builtin().option(opProvide)
+}
@@ -0,0 +1,39 @@
+package shaft_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+type tenant string
+
+type tenantStore struct {
+	tenant tenant
+}
+
+func TestPartitioned(t *testing.T) {
+	assert := assert.New(t)
+
+	constructed := make(map[tenant]int)
+	var stores []*tenantStore
+	assert.NoError(shaft.Run(
+		shaft.Partitioned(func(key tenant) *tenantStore {
+			constructed[key]++
+			return &tenantStore{tenant: key}
+		}),
+		shaft.Invoke(func(p *shaft.Partition[tenant, *tenantStore]) {
+			stores = append(stores, p.Get("alice"), p.Get("bob"))
+		}),
+		shaft.Invoke(func(p *shaft.Partition[tenant, *tenantStore]) {
+			stores = append(stores, p.Get("alice"))
+		}),
+	))
+	assert.Len(stores, 3)
+	assert.Equal(tenant("alice"), stores[0].tenant)
+	assert.Equal(tenant("bob"), stores[1].tenant)
+	assert.Same(stores[0], stores[2])
+	assert.Equal(map[tenant]int{"alice": 1, "bob": 1}, constructed)
+}
@@ -1,10 +1,14 @@
 package shaft
 
 import (
+	"context"
 	"fmt"
+	"math/rand"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/aegistudio/shaft/core"
 )
@@ -22,15 +26,50 @@ func convertSingle(item reflect.Type) core.Spec {
 	}
 }
 
-func convertFunc(args, rets []reflect.Type) (in, out []core.Spec) {
+// SpecOf converts the type into the spec just like Provide,
+// that is, a slice type is interpreted as a group, so that the
+// nodes inserted by core directly agree with the others.
+func SpecOf(typ reflect.Type) core.Spec {
+	return convertSingle(typ)
+}
+
+// convertType converts the item specifying a type, which is
+// either a reflect.Type or a pointer to it, e.g. new(T).
+func convertType(item interface{}) reflect.Type {
+	if typ, ok := item.(reflect.Type); ok {
+		return typ
+	}
+	return reflect.TypeOf(item).Elem()
+}
+
+func convertFunc(
+	args []reflect.Type, argTags []paramTag,
+	rets []reflect.Type, retTags []paramTag,
+) (in, out []core.Spec) {
 	inMap := make(map[core.Spec][]int)
 	for i, arg := range args {
-		spec := convertSingle(arg)
+		var spec core.Spec
+		if arg.Implements(typeLazyPort) {
+			port := reflect.Zero(arg).Interface().(lazyPort)
+			spec = convertSingle(port.lazyType())
+			spec.Lazy = true
+		} else if arg == typeScopedLogger {
+			spec = convertSingle(typeLogger)
+		} else {
+			spec = convertSingle(arg)
+		}
+		if i < len(argTags) {
+			spec.Name = argTags[i].name
+			spec.Optional = argTags[i].optional
+		}
 		in = append(in, spec)
 		inMap[spec] = append(inMap[spec], i)
 	}
 	for i, ret := range rets {
 		spec := convertSingle(ret)
+		if i < len(retTags) {
+			spec.Name = retTags[i].name
+		}
 		out = append(out, spec)
 		if matches := inMap[spec]; len(matches) > 0 {
 			out[i].Decorate = true
@@ -51,6 +90,24 @@ const (
 	opStack
 	opSupply
 	opPopulate
+	opListProviders
+	opDecorateOrder
+	opProvideValueAndPtr
+	opProvideOnMainThread
+	opDecorateFallback
+	opPopulateStruct
+	opProvideMapAsGroup
+	opDeprecated
+	opDecorate
+	opProvideHealthCheck
+	opProvideRetry
+	opNamed
+	opExtract
+	opFillStruct
+	opReplace
+	opProvideGroup
+	opAugmentGroup
+	opProvideTimeout
 )
 
 func (o op) String() string {
@@ -65,6 +122,42 @@ func (o op) String() string {
 		return "Supply"
 	case opPopulate:
 		return "Populate"
+	case opListProviders:
+		return "ListProviders"
+	case opDecorateOrder:
+		return "DecorateOrder"
+	case opProvideValueAndPtr:
+		return "ProvideValueAndPtr"
+	case opProvideOnMainThread:
+		return "ProvideOnMainThread"
+	case opDecorateFallback:
+		return "DecorateFallback"
+	case opPopulateStruct:
+		return "PopulateStruct"
+	case opProvideMapAsGroup:
+		return "ProvideMapAsGroup"
+	case opDeprecated:
+		return "Deprecated"
+	case opDecorate:
+		return "Decorate"
+	case opProvideHealthCheck:
+		return "ProvideHealthCheck"
+	case opProvideRetry:
+		return "ProvideRetry"
+	case opNamed:
+		return "Named"
+	case opExtract:
+		return "Extract"
+	case opFillStruct:
+		return "FillStruct"
+	case opReplace:
+		return "Replace"
+	case opProvideGroup:
+		return "ProvideGroup"
+	case opAugmentGroup:
+		return "AugmentGroup"
+	case opProvideTimeout:
+		return "ProvideTimeout"
 	default:
 		return "Unknown"
 	}
@@ -88,6 +181,10 @@ func (o funcOp) String() string {
 type valuesOp struct {
 	op    op
 	types []reflect.Type
+
+	// caller is the file:line where the op is written, it
+	// is empty if the caller is unknown.
+	caller string
 }
 
 func (o valuesOp) String() string {
@@ -95,18 +192,35 @@ func (o valuesOp) String() string {
 	for _, typ := range o.types {
 		names = append(names, typ.String())
 	}
-	return fmt.Sprintf("%s(%s)", o.op, strings.Join(names, ","))
+	result := fmt.Sprintf("%s(%s)", o.op, strings.Join(names, ","))
+	if o.caller != "" {
+		result = fmt.Sprintf("%s at %s", result, o.caller)
+	}
+	return result
+}
+
+// caller retrieves the file:line of the caller, where the
+// skip is the same as the one specified to runtime.Caller.
+func caller(skip int) string {
+	_, file, line, ok := runtime.Caller(skip + 1)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", filepath.Base(file), line)
 }
 
 var typeError = reflect.TypeOf((*error)(nil)).Elem()
 
-// Provide a function as constructor.
-//
-// The provided f must be a function, objects required by
-// the function is present in the argument list, and the
-// objects created by the function is in the result. And the
-// function can return an error as last result optionally.
-func Provide(f interface{}) Option {
+// providerFunc is the converted form of provided function.
+type providerFunc struct {
+	val        reflect.Value
+	args       []reflect.Type
+	in, out    []core.Spec
+	call       func([]reflect.Value) ([]reflect.Value, error)
+	infallible bool
+}
+
+func convertProvider(f interface{}) providerFunc {
 	val := reflect.ValueOf(f)
 	if val.Kind() != reflect.Func {
 		panic(fmt.Sprintf("invalid non-func %T provided", f))
@@ -130,16 +244,369 @@ func Provide(f interface{}) Option {
 	if len(rets) == 0 {
 		panic(fmt.Sprintf("func %v must provide result", f))
 	}
-	in, out := convertFunc(args, rets)
-	return core.Provide(func(in []reflect.Value) ([]reflect.Value, error) {
-		var err error
-		out := val.Call(in)
-		if returnsError {
-			err, _ = out[len(out)-1].Interface().(error)
-			out = out[:len(out)-1]
+	args, argTags, pack := expandParams(args)
+	rets, retTags, unpack := expandResults(rets)
+	in, out := convertFunc(args, argTags, rets, retTags)
+	convert := convertLazy(args)
+	return providerFunc{
+		val:        val,
+		args:       args,
+		in:         in,
+		out:        out,
+		infallible: !returnsError,
+		call: func(in []reflect.Value) ([]reflect.Value, error) {
+			var err error
+			out := val.Call(pack(convert(in)))
+			if returnsError {
+				err, _ = out[len(out)-1].Interface().(error)
+				out = out[:len(out)-1]
+			}
+			if err != nil {
+				return nil, err
+			}
+			return unpack(out), nil
+		},
+	}
+}
+
+func (p providerFunc) option(op op) Option {
+	format := funcOp{op: op, pc: p.val.Pointer()}
+	scope := convertScoped(p.args, format)
+	result := core.Provide(func(in []reflect.Value) ([]reflect.Value, error) {
+		return p.call(scope(in))
+	}, p.in, p.out, format)
+	if p.infallible {
+		result = core.Infallible(result)
+	}
+	return result
+}
+
+// builtin exempts the function provided by the framework from
+// RequireErrorReturns, which only concerns the user functions.
+func (p providerFunc) builtin() providerFunc {
+	p.infallible = false
+	return p
+}
+
+// requireWarnings appends *Warnings to the inputs, and
+// returns the function to strip it from the arguments.
+func (p *providerFunc) requireWarnings() func(
+	[]reflect.Value,
+) (*Warnings, []reflect.Value) {
+	p.in = append(p.in, convertSingle(reflect.TypeOf((*Warnings)(nil))))
+	return func(in []reflect.Value) (*Warnings, []reflect.Value) {
+		return in[len(in)-1].Interface().(*Warnings), in[:len(in)-1]
+	}
+}
+
+// requireContext appends Context to the inputs, and returns
+// the function to strip it from the arguments.
+func (p *providerFunc) requireContext() func(
+	[]reflect.Value,
+) (Context, []reflect.Value) {
+	p.in = append(p.in, convertSingle(reflect.TypeOf((*Context)(nil)).Elem()))
+	return func(in []reflect.Value) (Context, []reflect.Value) {
+		return in[len(in)-1].Interface().(Context), in[:len(in)-1]
+	}
+}
+
+// Provide a function as constructor.
+//
+// The provided f must be a function, objects required by
+// the function is present in the argument list, and the
+// objects created by the function is in the result. And the
+// function can return an error as last result optionally.
+func Provide(f interface{}) Option {
+	return convertProvider(f).option(opProvide)
+}
+
+// ProvideIf provides the function as constructor just like
+// Provide if cond is true, and provides nothing otherwise,
+// e.g. enabling a feature by a command line flag.
+//
+// A false condition leaves the types genuinely unprovided, so
+// their consumers must be conditional themselves or consume
+// them optionally, otherwise the dependencies are missing.
+func ProvideIf(cond bool, f interface{}) Option {
+	if !cond {
+		return Module()
+	}
+	return Provide(f)
+}
+
+// Named provides a function as constructor, with its results
+// provided under the name, so that values of the same type can
+// coexist. The named values can be consumed by UseNamed.
+func Named(name string, f interface{}) Option {
+	p := convertProvider(f)
+	for i := range p.out {
+		p.out[i].Name = name
+		p.out[i].Decorate = false
+	}
+	for i := range p.in {
+		p.in[i].Decorate = false
+	}
+	return p.option(opNamed)
+}
+
+// Replace provides a function as constructor, with its results
+// replacing those provided by the other constructors instead of
+// being ambiguous with them, e.g. swapping a real *sql.DB for
+// a fake one in the tests, see also core.Replace.
+func Replace(f interface{}) Option {
+	p := convertProvider(f)
+	for i := range p.out {
+		p.out[i].Decorate = false
+	}
+	for i := range p.in {
+		p.in[i].Decorate = false
+	}
+	return core.Replace(p.option(opReplace))
+}
+
+// UseNamed makes the nodes specified by the options consume
+// the value of the type under the name, instead of the one
+// without a name. The type is specified as the argument of
+// convertType, e.g. new(*sql.DB).
+func UseNamed(name string, typ interface{}, opts ...Option) Option {
+	return core.NameInputs(name, convertType(typ), opts...)
+}
+
+// ProvideValueAndPtr provides a function as constructor, and
+// each of its (non-group) results are provided both as value
+// and as pointer to a stored copy of the value. So that the
+// consumers of both T and *T share the same provider.
+func ProvideValueAndPtr(f interface{}) Option {
+	p := convertProvider(f)
+	var indices []int
+	for i, spec := range p.out {
+		if spec.Group || spec.Decorate {
+			continue
+		}
+		indices = append(indices, i)
+		p.out = append(p.out, convertSingle(reflect.PtrTo(spec.Type)))
+	}
+	call := p.call
+	p.call = func(in []reflect.Value) ([]reflect.Value, error) {
+		out, err := call(in)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range indices {
+			ptr := reflect.New(out[i].Type())
+			ptr.Elem().Set(out[i])
+			out = append(out, ptr)
+		}
+		return out, nil
+	}
+	return p.option(opProvideValueAndPtr)
+}
+
+// DecorateFallback provides a function as decorator, which
+// falls back to the undecorated objects when the function
+// returns an error, and the error is emitted into the
+// *Warnings instead. The function must only decorate.
+func DecorateFallback(f interface{}) Option {
+	p := convertProvider(f)
+	var sources []int
+	for _, out := range p.out {
+		if !out.Decorate {
+			panic(fmt.Sprintf("func %v must only decorate", f))
+		}
+		for j, in := range p.in {
+			if in == out {
+				sources = append(sources, j)
+				break
+			}
+		}
+	}
+	strip := p.requireWarnings()
+	format := funcOp{op: opDecorateFallback, pc: p.val.Pointer()}
+	call := p.call
+	p.call = func(in []reflect.Value) ([]reflect.Value, error) {
+		warnings, in := strip(in)
+		out, err := call(in)
+		if err == nil {
+			return out, nil
+		}
+		warnings.Warn("%s fallback: %v", format, err)
+		out = make([]reflect.Value, len(sources))
+		for i, j := range sources {
+			out[i] = in[j]
+		}
+		return out, nil
+	}
+	return core.Decorate(p.option(opDecorateFallback))
+}
+
+// Deprecated provides a function as a deprecated constructor,
+// which emits a warning with the message (e.g. suggesting the
+// replacement) into the *Warnings when it is executed, that is,
+// only when it is actually used.
+func Deprecated(msg string, f interface{}) Option {
+	p := convertProvider(f)
+	strip := p.requireWarnings()
+	format := funcOp{op: opDeprecated, pc: p.val.Pointer()}
+	call := p.call
+	p.call = func(in []reflect.Value) ([]reflect.Value, error) {
+		warnings, in := strip(in)
+		warnings.Warn("%s is deprecated: %s", format, msg)
+		return call(in)
+	}
+	return p.option(opDeprecated)
+}
+
+// ProvideOnMainThread provides a function as constructor,
+// which is executed with its goroutine wired to the current
+// OS thread by runtime.LockOSThread, as required by libraries
+// with thread affinity (e.g. OpenGL, Cocoa).
+//
+// To construct on the main thread, the main goroutine must
+// lock the OS thread in an init function, and call Run in
+// the main goroutine.
+func ProvideOnMainThread(f interface{}) Option {
+	p := convertProvider(f)
+	call := p.call
+	p.call = func(in []reflect.Value) ([]reflect.Value, error) {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		return call(in)
+	}
+	return core.Sequential(p.option(opProvideOnMainThread))
+}
+
+// Decorate provides a function as decorator explicitly.
+//
+// It is just like Provide, but the function must decorate,
+// and it is allowed when NoImplicitDecorate is specified.
+func Decorate(f interface{}) Option {
+	return decorate(0, f, opDecorate)
+}
+
+// DecorateOrder provides a function as decorator with the
+// specified order.
+//
+// The decorators of the same type are applied from the lower
+// order to the higher order, and those of the same order are
+// applied in the order of provision. The decorators provided
+// by Provide are of order 0.
+func DecorateOrder(order int, f interface{}) Option {
+	return decorate(order, f, opDecorateOrder)
+}
+
+func decorate(order int, f interface{}, op op) Option {
+	p := convertProvider(f)
+	decorate := false
+	for i := range p.out {
+		if p.out[i].Decorate {
+			p.out[i].Order = order
+			decorate = true
 		}
-		return out, err
-	}, in, out, funcOp{op: opProvide, pc: val.Pointer()})
+	}
+	if !decorate {
+		panic(fmt.Sprintf("func %v must decorate", f))
+	}
+	return core.Decorate(p.option(op))
+}
+
+// Cacheable provides a pure function as constructor.
+//
+// The results of the function are memoized when the compiled
+// Program is run again, while the other nodes are executed
+// on every run.
+func Cacheable(f interface{}) Option {
+	return core.Cacheable(Provide(f))
+}
+
+// ProvideTTL is like Cacheable, but the memoized results of
+// the function expire after d, and the function is called
+// again when the Program is run after that.
+func ProvideTTL(d time.Duration, f interface{}) Option {
+	return ProvideTTLClock(d, time.Now, f)
+}
+
+// ProvideTTLClock is like ProvideTTL, but the current time is
+// retrieved by calling now, so that the expiry can be tested
+// with a fake clock.
+func ProvideTTLClock(
+	d time.Duration, now func() time.Time, f interface{},
+) Option {
+	return core.CacheableTTL(d, now, Provide(f))
+}
+
+// ProvideRetry is like Cacheable, but the function is retried
+// for at most attempts times when it returns an error, with an
+// exponential backoff starting from backoff plus a random
+// jitter. Only the successful results are memoized, so that
+// the function is retried again in the next run of a Program
+// or Container if all attempts have failed.
+//
+// The backoff is interrupted once the Context of the run is
+// done, and the error of the context is returned then.
+func ProvideRetry(
+	attempts int, backoff time.Duration, f interface{},
+) Option {
+	p := convertProvider(f)
+	strip := p.requireContext()
+	call := p.call
+	p.call = func(in []reflect.Value) ([]reflect.Value, error) {
+		ctx, in := strip(in)
+		delay := backoff
+		for attempt := 1; ; attempt++ {
+			out, err := call(in)
+			if err == nil || attempt >= attempts {
+				return out, err
+			}
+			jitter := time.Duration(0)
+			if delay > 0 {
+				jitter = time.Duration(rand.Int63n(int64(delay)))
+			}
+			timer := time.NewTimer(delay + jitter)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+			delay *= 2
+		}
+	}
+	return core.Cacheable(p.option(opProvideRetry))
+}
+
+// ProvideTimeout provides a function as constructor, which
+// fails with an error wrapping context.DeadlineExceeded if it
+// has not returned after d, e.g. dialing a remote service, so
+// that each constructor is given a bounded startup time.
+//
+// The function is called in another goroutine, which cannot
+// be preempted and keeps running after the timeout, so it is
+// leaked until the function returns, and its results are
+// discarded then.
+func ProvideTimeout(d time.Duration, f interface{}) Option {
+	p := convertProvider(f)
+	call := p.call
+	p.call = func(in []reflect.Value) ([]reflect.Value, error) {
+		type result struct {
+			out []reflect.Value
+			err error
+		}
+		done := make(chan result, 1)
+		go func() {
+			out, err := call(in)
+			done <- result{out: out, err: err}
+		}()
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case r := <-done:
+			return r.out, r.err
+		case <-timer.C:
+			return nil, fmt.Errorf(
+				"timeout after %s: %w", d, context.DeadlineExceeded)
+		}
+	}
+	return p.option(opProvideTimeout)
 }
 
 // Supply an objects to dependency injection.
@@ -155,7 +622,70 @@ func Provide(f interface{}) Option {
 // You might also specify the interface types of this object
 // when supplying, otherwise the actual underlying object
 // will have been supplied to them.
+//
+// The location where Supply is called is recorded, and will
+// be displayed in the error messages.
 func Supply(obj interface{}, infcs ...interface{}) Option {
+	option, err := trySupply(caller(1), obj, infcs...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return option
+}
+
+// SupplyIf supplies the object just like Supply if cond is
+// true, and supplies nothing otherwise, see also ProvideIf.
+func SupplyIf(cond bool, obj interface{}, infcs ...interface{}) Option {
+	if !cond {
+		return Module()
+	}
+	option, err := trySupply(caller(1), obj, infcs...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return option
+}
+
+// SupplyAs is like Supply, but the object is supplied solely
+// as the interfaces specified by infcs, and the concrete type
+// is never supplied, which prevents the consumers coupling to
+// the implementation accidentally.
+//
+// At least one of the infcs must be specified, and each of
+// them must be a pointer or slice of interface type.
+func SupplyAs(obj interface{}, infcs ...interface{}) Option {
+	if len(infcs) == 0 {
+		panic(fmt.Sprintf("type %T must be supplied as interfaces", obj))
+	}
+	for _, infc := range infcs {
+		typ := reflect.TypeOf(infc)
+		if typ.Kind() != reflect.Ptr && typ.Kind() != reflect.Slice {
+			continue
+		}
+		if typ.Elem().Kind() != reflect.Interface {
+			panic(fmt.Sprintf(
+				"type %T cannot be supplied as non-interface %s",
+				obj, typ.Elem()))
+		}
+	}
+	option, err := trySupply(caller(1), obj, infcs...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return option
+}
+
+// TrySupply is like Supply, but returns an error instead of
+// panicking when the infcs are invalid or the object cannot
+// be converted to them. It is useful when building the
+// Supply dynamically.
+func TrySupply(obj interface{}, infcs ...interface{}) (Option, error) {
+	return trySupply(caller(1), obj, infcs...)
+}
+
+func trySupply(
+	caller string, obj interface{}, infcs ...interface{},
+) (Option, error) {
 	value := reflect.ValueOf(obj)
 	var values []reflect.Value
 	var types []reflect.Type
@@ -172,20 +702,29 @@ func Supply(obj interface{}, infcs ...interface{}) Option {
 		switch typ.Kind() {
 		case reflect.Ptr:
 			typ = typ.Elem()
+			if !value.Type().ConvertibleTo(typ) {
+				return nil, fmt.Errorf(
+					"type %T cannot be converted to %s", obj, typ)
+			}
 			val = value.Convert(typ)
 		case reflect.Slice:
+			if !value.Type().ConvertibleTo(typ.Elem()) {
+				return nil, fmt.Errorf(
+					"type %T cannot be converted to %s",
+					obj, typ.Elem())
+			}
 			val = reflect.MakeSlice(typ, 0, 1)
 			val = reflect.Append(val, value.Convert(typ.Elem()))
 		default:
-			panic(fmt.Sprintf(
-				"type %T must be pointer or slice", infc))
+			return nil, fmt.Errorf(
+				"type %T must be pointer or slice", infc)
 		}
 		values = append(values, val)
 		types = append(types, typ)
 		spec = append(spec, convertSingle(typ))
 	}
 	return core.Supply(values, spec,
-		valuesOp{op: opSupply, types: types})
+		valuesOp{op: opSupply, types: types, caller: caller}), nil
 }
 
 // Invoke a function as consumer.
@@ -195,6 +734,52 @@ func Supply(obj interface{}, infcs ...interface{}) Option {
 // of this function is ignored, except for the last result
 // being an error, and the error is returned then.
 func Invoke(f interface{}) Option {
+	return invoke(f, opInvoke, nil)
+}
+
+// Extract a function as consumer just like Invoke, but the
+// results of the function, except for the last result being
+// an error, are copied out into the pointers just like
+// Populate, so that a value computed from the dependency
+// injection can be retrieved in one call.
+//
+// The number of pointers must match the number of results,
+// and the pointers are written only when no error returned.
+func Extract(f interface{}, ptrs ...interface{}) Option {
+	typ := reflect.TypeOf(f)
+	if typ == nil || typ.Kind() != reflect.Func {
+		panic(fmt.Sprintf("invalid non-func %T provided", f))
+	}
+	numRets := typ.NumOut()
+	if numRets > 0 && typ.Out(numRets-1) == typeError {
+		numRets--
+	}
+	if len(ptrs) != numRets {
+		panic(fmt.Sprintf("func %v has %d results but %d ptrs requested",
+			f, numRets, len(ptrs)))
+	}
+	var values []reflect.Value
+	for i, ptr := range ptrs {
+		value := reflect.ValueOf(ptr)
+		if value.Kind() != reflect.Ptr {
+			panic(fmt.Sprintf("invalid non-ptr %T requested", ptr))
+		}
+		if !typ.Out(i).AssignableTo(value.Type().Elem()) {
+			panic(fmt.Sprintf("result %v cannot be assigned to %T",
+				typ.Out(i), ptr))
+		}
+		values = append(values, value)
+	}
+	return invoke(f, opExtract, func(out []reflect.Value) {
+		for i := range values {
+			values[i].Elem().Set(out[i])
+		}
+	})
+}
+
+// invoke converts the function into a consumer, with the
+// results passed to export if no error returned.
+func invoke(f interface{}, op op, export func([]reflect.Value)) Option {
 	val := reflect.ValueOf(f)
 	if val.Kind() != reflect.Func {
 		panic(fmt.Sprintf("invalid non-func %T provided", f))
@@ -210,18 +795,37 @@ func Invoke(f interface{}) Option {
 	if numRets > 0 && typ.Out(numRets-1) == typeError {
 		returnsError = true
 	}
-	in, _ := convertFunc(args, nil)
+	args, tags, pack := expandParams(args)
+	in, _ := convertFunc(args, tags, nil, nil)
+	convert := convertLazy(args)
+	format := funcOp{op: op, pc: val.Pointer()}
+	scope := convertScoped(args, format)
 	return core.Invoke(func(in []reflect.Value) error {
 		var err error
-		out := val.Call(in)
+		out := val.Call(pack(convert(scope(in))))
 		if returnsError {
 			err, _ = out[len(out)-1].Interface().(error)
+			out = out[:len(out)-1]
+		}
+		if err == nil && export != nil {
+			export(out)
 		}
 		return err
-	}, in, funcOp{op: opInvoke, pc: val.Pointer()})
+	}, in, format)
+}
+
+// AfterAll invokes a function after all the other nodes in
+// the execution plan have been executed, see also Invoke.
+func AfterAll(f interface{}) Option {
+	return core.AfterAll(Invoke(f))
 }
 
 // Populate objects from the dependency injection.
+//
+// Each of the objects must be a pointer, and the value of the
+// pointee type is requested, which might be an interface. The
+// pointer to a slice []T is populated with the whole group []T
+// collected from all of its providers.
 func Populate(objs ...interface{}) Option {
 	var values []reflect.Value
 	var types []reflect.Type
@@ -240,6 +844,51 @@ func Populate(objs ...interface{}) Option {
 		valuesOp{op: opPopulate, types: types})
 }
 
+// PopulateStruct populates the exported fields of the struct
+// pointed by obj from the dependency injection, each of the
+// fields is requested by its type just like Populate.
+func PopulateStruct(obj interface{}) Option {
+	return populateFields(obj, opPopulateStruct,
+		func(reflect.Value) bool { return true })
+}
+
+// FillStruct fills the zero-valued exported fields of the
+// struct pointed by obj from the dependency injection, just
+// like PopulateStruct, but leaves the fields already set
+// untouched, e.g. a config struct handed over by another
+// framework. The fields are inspected when FillStruct is
+// called, and only the zero-valued ones are consumed.
+func FillStruct(obj interface{}) Option {
+	return populateFields(obj, opFillStruct,
+		func(field reflect.Value) bool { return field.IsZero() })
+}
+
+// populateFields populates the exported fields of the struct
+// pointed by obj which are accepted by the predicate.
+func populateFields(
+	obj interface{}, op op, accept func(reflect.Value) bool,
+) Option {
+	value := reflect.ValueOf(obj)
+	typ := value.Type()
+	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("invalid non-struct-ptr %T requested", obj))
+	}
+	var values []reflect.Value
+	var spec []core.Spec
+	numFields := typ.Elem().NumField()
+	for i := 0; i < numFields; i++ {
+		field := typ.Elem().Field(i)
+		if field.PkgPath != "" || !accept(value.Elem().Field(i)) {
+			continue
+		}
+		values = append(values, value.Elem().Field(i).Addr())
+		spec = append(spec, convertSingle(field.Type))
+	}
+	return core.Populate(values, spec, valuesOp{
+		op: op, types: []reflect.Type{typ},
+	})
+}
+
 // Stack a function as constructor.
 //
 // The provided f must be a function, its first argument must
@@ -249,6 +898,35 @@ func Populate(objs ...interface{}) Option {
 // required by this function. The inner function pointer must
 // return an error, and so do the function, as there could
 // always be some module returning error.
+//
+// The remainder of the execution plan is executed inside the
+// callback, so the code after calling the callback (usually
+// the deferred cleanup) is always executed in the strict
+// reverse order of the execution plan, even if there's no
+// direct dependency between the stacked functions. That is,
+// the cleanup is LIFO just like the defer written by hand:
+// the frame started last unwinds first, where the stacked
+// functions are started in the order of the consumers
+// requiring them, and the dependencies before the dependents.
+//
+// The callback returns the error of the remainder of the
+// execution plan, so the cleanup can be performed only on
+// failure (e.g. rolling back a created resource) or only on
+// success (e.g. committing it) by checking the error:
+//
+//	func(f func(*Resource) error) error {
+//		r := createResource()
+//		if err := f(r); err != nil {
+//			r.Rollback()
+//			return err
+//		}
+//		return r.Commit()
+//	}
+//
+// The members of the groups passed to the callback are merged
+// with those of the other providers, and they are collected in
+// the order of provision just like the other members, no matter
+// whether the other members are provided inside its scope.
 func Stack(f interface{}) Option {
 	val := reflect.ValueOf(f)
 	if val.Kind() != reflect.Func {
@@ -280,7 +958,11 @@ func Stack(f interface{}) Option {
 	for i := 0; i < numRets; i++ {
 		rets = append(rets, callbackTyp.In(i))
 	}
-	in, out := convertFunc(args, rets)
+	args, tags, pack := expandParams(args)
+	in, out := convertFunc(args, tags, rets, nil)
+	convert := convertLazy(args)
+	format := funcOp{op: opStack, pc: val.Pointer()}
+	scope := convertScoped(args, format)
 	return core.Stack(func(
 		g func(out []reflect.Value) error, in []reflect.Value,
 	) error {
@@ -296,9 +978,9 @@ func Stack(f interface{}) Option {
 				return result
 			},
 		))
-		args = append(args, in...)
+		args = append(args, pack(convert(scope(in)))...)
 		out := val.Call(args)
 		err, _ := out[0].Interface().(error)
 		return err
-	}, in, out, funcOp{op: opStack, pc: val.Pointer()})
+	}, in, out, format)
 }
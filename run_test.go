@@ -1,12 +1,20 @@
 package shaft_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"reflect"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/aegistudio/shaft"
+	"github.com/aegistudio/shaft/core"
 )
 
 type I interface {
@@ -96,3 +104,1216 @@ func TestStandard(t *testing.T) {
 		"defer b",
 	})
 }
+
+type chainA struct{}
+
+type chainB struct{}
+
+type chainC struct{}
+
+func TestStackUnwindOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.Stack(func(
+			f func(*chainC) error, events *[]string, _ *chainB,
+		) error {
+			*events = append(*events, "start c")
+			defer func() { *events = append(*events, "stop c") }()
+			return f(&chainC{})
+		}),
+		shaft.Stack(func(
+			f func(*chainA) error, events *[]string,
+		) error {
+			*events = append(*events, "start a")
+			defer func() { *events = append(*events, "stop a") }()
+			return f(&chainA{})
+		}),
+		shaft.Stack(func(
+			f func(*chainB) error, events *[]string, _ *chainA,
+		) error {
+			*events = append(*events, "start b")
+			defer func() { *events = append(*events, "stop b") }()
+			return f(&chainB{})
+		}),
+		shaft.Invoke(func(events *[]string, _ *chainC) {
+			*events = append(*events, "invoke")
+		}),
+	))
+	assert.Equal([]string{
+		"start a", "start b", "start c",
+		"invoke",
+		"stop c", "stop b", "stop a",
+	}, events)
+}
+
+func stackEvents[T any](name string, value T) shaft.Option {
+	return shaft.Stack(func(f func(T) error, events *[]string) error {
+		*events = append(*events, "start "+name)
+		defer func() { *events = append(*events, "stop "+name) }()
+		return f(value)
+	})
+}
+
+func TestStackIndependentUnwindOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, concurrency := range []int{1, 4} {
+		var events []string
+		assert.NoError(shaft.Run(
+			shaft.WithConcurrency(concurrency),
+			shaft.Supply(&events),
+			stackEvents("c", &chainC{}),
+			stackEvents("b", &chainB{}),
+			stackEvents("a", &chainA{}),
+			shaft.Invoke(func(events *[]string, _ *chainA) {
+				*events = append(*events, "invoke a")
+			}),
+			shaft.Invoke(func(events *[]string, _ *chainB, _ *chainC) {
+				*events = append(*events, "invoke bc")
+			}),
+		))
+		assert.Equal([]string{
+			"start a", "invoke a",
+			"start b", "start c", "invoke bc",
+			"stop c", "stop b", "stop a",
+		}, events)
+	}
+}
+
+func TestTrySupply(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := shaft.TrySupply(&A{}, (*I)(nil))
+	assert.NoError(err)
+	_, err = shaft.TrySupply(&C{}, (*I)(nil))
+	assert.EqualError(err,
+		"type *shaft_test.C cannot be converted to shaft_test.I")
+	_, err = shaft.TrySupply(&C{}, ([]I)(nil))
+	assert.EqualError(err,
+		"type *shaft_test.C cannot be converted to shaft_test.I")
+	_, err = shaft.TrySupply(A{}, A{})
+	assert.EqualError(err,
+		"type shaft_test.A must be pointer or slice")
+	assert.Panics(func() {
+		shaft.Supply(&C{}, (*I)(nil))
+	})
+}
+
+func TestSupplyAs(t *testing.T) {
+	assert := assert.New(t)
+
+	var single I
+	var group []I
+	assert.NoError(shaft.Run(
+		shaft.SupplyAs(&A{}, (*I)(nil), ([]I)(nil)),
+		shaft.Populate(&single, &group),
+	))
+	assert.Equal(&A{}, single)
+	assert.Equal([]I{&A{}}, group)
+
+	err := shaft.Run(
+		shaft.SupplyAs(&A{}, (*I)(nil)),
+		shaft.Invoke(func(*A) {}),
+	)
+	assert.ErrorContains(err, "type *shaft_test.A missing dependency")
+	infos, err := shaft.ProvidedBy(shaft.SupplyAs(&A{}, (*I)(nil)))
+	assert.NoError(err)
+	assert.Len(infos, 1)
+	assert.Equal(reflect.TypeOf((*I)(nil)).Elem(), infos[0].Spec.Type)
+
+	assert.Panics(func() {
+		shaft.SupplyAs(&A{})
+	})
+	assert.Panics(func() {
+		shaft.SupplyAs(&A{}, (**A)(nil))
+	})
+}
+
+func TestOneOf(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.OneOf(shaft.Provide(redundantObjectC)),
+		shaft.Invoke(func(*C) {}),
+	))
+	assert.Equal([]string{"provide c"}, events)
+
+	_, _, line, _ := runtime.Caller(0)
+	supplyC := shaft.Supply(&C{})
+	err := shaft.Run(
+		shaft.Supply(&events),
+		shaft.OneOf(shaft.Provide(redundantObjectC), supplyC),
+		shaft.Invoke(func(*C) {}),
+	)
+	var conflict *core.ErrConflict
+	assert.ErrorAs(err, &conflict)
+	assert.Equal([]string{
+		"Provide(github.com/aegistudio/shaft_test.redundantObjectC)",
+		fmt.Sprintf("Supply(*shaft_test.C) at run_test.go:%d", line+1),
+	}, conflict.Nodes)
+	assert.Equal("*shaft_test.C", conflict.Type)
+
+	// The nodes conflict even if their outputs are not consumed.
+	err = shaft.Run(
+		shaft.OneOf(shaft.Supply(&A{}), shaft.Supply(&B{})),
+	)
+	assert.ErrorAs(err, &conflict)
+	assert.Len(conflict.Nodes, 2)
+	assert.Empty(conflict.Type)
+}
+
+func TestCacheable(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	var groups [][]I
+	program, err := shaft.Compile(
+		shaft.Supply(&events),
+		shaft.Cacheable(provideObjectA),
+		shaft.Provide(redundantObjectC),
+		shaft.Supply(&D{}),
+		shaft.Invoke(func(inputs []I, _ *C) {
+			groups = append(groups, inputs)
+		}),
+	)
+	assert.NoError(err)
+	assert.NoError(program.Run())
+	assert.NoError(program.Run())
+	assert.Equal([]string{
+		"provide a", "provide c", "provide c",
+	}, events)
+	assert.Len(groups, 2)
+	assert.Len(groups[0], 1)
+	assert.Equal(groups[0], groups[1])
+}
+
+func TestCacheableTTL(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	now := time.Unix(0, 0)
+	program, err := shaft.Compile(
+		shaft.Supply(&events),
+		core.CacheableTTL(time.Minute, func() time.Time {
+			return now
+		}, shaft.Provide(provideObjectA)),
+		shaft.Supply(&D{}),
+		shaft.Invoke(func([]I) {}),
+	)
+	assert.NoError(err)
+	assert.NoError(program.Run())
+	now = now.Add(30 * time.Second)
+	assert.NoError(program.Run())
+	assert.Equal([]string{"provide a"}, events)
+	now = now.Add(30 * time.Second)
+	assert.NoError(program.Run())
+	assert.NoError(program.Run())
+	assert.Equal([]string{"provide a", "provide a"}, events)
+}
+
+func TestProvideTTLClock(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	now := time.Unix(0, 0)
+	container := shaft.NewContainer(
+		shaft.ProvideTTLClock(time.Minute, func() time.Time {
+			return now
+		}, func() *D {
+			events = append(events, "provide d")
+			return &D{}
+		}),
+	)
+	invoke := shaft.Invoke(func(*D) {})
+	assert.NoError(container.Run(invoke))
+	now = now.Add(30 * time.Second)
+	assert.NoError(container.Run(invoke))
+	assert.Equal([]string{"provide d"}, events)
+	now = now.Add(30 * time.Second)
+	assert.NoError(container.Run(invoke))
+	assert.NoError(container.Run(invoke))
+	assert.Equal([]string{"provide d", "provide d"}, events)
+}
+
+func TestProgramStep(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	program, err := shaft.Compile(
+		shaft.Provide(func() *D {
+			events = append(events, "provide d")
+			return &D{}
+		}),
+		shaft.Stack(func(f func(*C) error, _ *D) error {
+			events = append(events, "enter c")
+			defer func() { events = append(events, "leave c") }()
+			return f(&C{})
+		}),
+		shaft.Invoke(func(*C) {
+			events = append(events, "invoke")
+		}),
+	)
+	assert.NoError(err)
+	for round := 0; round < 2; round++ {
+		events = nil
+		var steps [][]string
+		for {
+			done, err := program.Step()
+			assert.NoError(err)
+			steps = append(steps, append([]string(nil), events...))
+			if done {
+				break
+			}
+		}
+		assert.Equal([][]string{
+			{"provide d"},
+			{"provide d", "enter c"},
+			{"provide d", "enter c", "invoke", "leave c"},
+		}, steps)
+	}
+}
+
+func TestContainerBuiltins(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	buf := &bytes.Buffer{}
+	container := shaft.NewContainer(
+		shaft.Supply(shaft.LogWriter{Writer: buf}),
+		shaft.Provide(func(lc *shaft.Lifecycle, logger *log.Logger) *D {
+			lc.OnStop(func() error {
+				events = append(events, "stop d")
+				return nil
+			})
+			logger.Print("provide d")
+			return &D{}
+		}),
+	)
+	for round := 0; round < 2; round++ {
+		assert.NoError(container.Run(shaft.Invoke(func(
+			*D, *shaft.Warnings, shaft.PlanInfo, shaft.StartupDuration,
+		) {
+			events = append(events, "invoke")
+		})))
+	}
+	assert.Equal([]string{
+		"invoke", "stop d", "invoke", "stop d",
+	}, events)
+	assert.Contains(buf.String(), "provide d")
+}
+
+func TestContainer(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	container := shaft.NewContainer(
+		shaft.Supply(&events),
+		shaft.Provide(redundantObjectC),
+	)
+	assert.NoError(container.Run(shaft.Invoke(func(*C) {
+		events = append(events, "invoke c")
+	})))
+	assert.NoError(container.Run(shaft.Invoke(func(*C) {
+		events = append(events, "invoke c again")
+	})))
+	assert.Error(container.Run(shaft.Invoke(func([]I, *D) {})))
+	container.Add(
+		shaft.Supply(&D{}),
+		shaft.Provide(provideObjectA),
+		shaft.Invoke(func(inputs []I) {
+			events = append(events, fmt.Sprintf("invoke %d", len(inputs)))
+		}),
+	)
+	assert.NoError(container.Run())
+	assert.NoError(container.Run())
+	assert.Equal([]string{
+		"provide c", "invoke c",
+		"provide c", "invoke c again",
+		"provide a", "invoke 1",
+		"provide a", "invoke 1",
+	}, events)
+}
+
+func TestProvideRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	errTransient := errors.New("transient error")
+	var attempts int
+	container := shaft.NewContainer(
+		shaft.ProvideRetry(2, time.Millisecond, func() (*C, error) {
+			attempts++
+			if attempts <= 3 {
+				return nil, errTransient
+			}
+			return &C{}, nil
+		}),
+	)
+	var c1, c2 *C
+	assert.ErrorIs(container.Run(shaft.Populate(&c1)), errTransient)
+	assert.Equal(2, attempts)
+	assert.NoError(container.Run(shaft.Populate(&c1)))
+	assert.Equal(4, attempts)
+	assert.NoError(container.Run(shaft.Populate(&c2)))
+	assert.Equal(4, attempts)
+	assert.Same(c1, c2)
+
+	// The backoff is interrupted when the run is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	start := time.Now()
+	err := shaft.RunContext(ctx,
+		shaft.ProvideRetry(2, time.Minute, func() (*C, error) {
+			cancel()
+			return nil, errTransient
+		}),
+		shaft.Populate(&c1),
+	)
+	assert.ErrorIs(err, context.Canceled)
+	assert.Less(time.Since(start), time.Minute)
+
+	// The program is also supplied with the run context.
+	attempts = 0
+	program, err := shaft.Compile(
+		shaft.ProvideRetry(2, time.Millisecond, func() (*C, error) {
+			attempts++
+			if attempts <= 1 {
+				return nil, errTransient
+			}
+			return &C{}, nil
+		}),
+		shaft.Invoke(func(*C) {}),
+	)
+	assert.NoError(err)
+	assert.NoError(program.Run())
+	assert.Equal(2, attempts)
+}
+
+type recordObserver struct {
+	events []string
+}
+
+func (r *recordObserver) OnNodeStart(name string) {
+	r.events = append(r.events, "start "+name)
+}
+
+func (r *recordObserver) OnNodeFinish(
+	name string, err error, d time.Duration,
+) {
+	r.events = append(r.events, fmt.Sprintf("finish %s: %v", name, err))
+}
+
+func TestWithObserver(t *testing.T) {
+	assert := assert.New(t)
+
+	errFailed := errors.New("failed")
+	observer := &recordObserver{}
+	program, err := shaft.Compile(
+		shaft.WithObserver(observer),
+		shaft.Provide(provideChainA),
+		shaft.Provide(func(*chainA) (*chainB, error) {
+			return nil, errFailed
+		}),
+		shaft.Invoke(func(*chainB) {}),
+	)
+	assert.NoError(err)
+	assert.ErrorIs(program.Run(), errFailed)
+	assert.Len(observer.events, 4)
+	assert.Equal("start Provide(github.com/aegistudio/"+
+		"shaft_test.provideChainA)", observer.events[0])
+	assert.Equal("finish Provide(github.com/aegistudio/"+
+		"shaft_test.provideChainA): <nil>", observer.events[1])
+	assert.Contains(observer.events[2], "start Provide(")
+	assert.Contains(observer.events[3], "TestWithObserver")
+	assert.Contains(observer.events[3], ": failed")
+}
+
+func TestAfterAll(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.AfterAll(func(events *[]string) {
+			*events = append(*events, "ready")
+		}),
+		shaft.Provide(redundantObjectC),
+		shaft.Supply(&D{}),
+		shaft.Provide(provideObjectA),
+		shaft.Invoke(func(*C) {}),
+		shaft.Invoke(func([]I) {}),
+	))
+	assert.Equal([]string{
+		"provide c", "provide a", "ready",
+	}, events)
+}
+
+func TestStartupDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	var duration shaft.StartupDuration
+	assert.NoError(shaft.Run(
+		shaft.AfterAll(func(d shaft.StartupDuration) {
+			duration = d
+		}),
+		shaft.Provide(func() *chainA {
+			time.Sleep(time.Millisecond)
+			return &chainA{}
+		}),
+		shaft.Invoke(func(*chainA) {}),
+	))
+	assert.GreaterOrEqual(time.Duration(duration), time.Millisecond)
+}
+
+func TestRunContext(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	invoked := false
+	err := shaft.RunContext(ctx,
+		shaft.Provide(func() *chainA {
+			cancel()
+			return &chainA{}
+		}),
+		shaft.Provide(provideChainB),
+		shaft.Invoke(func(*chainB) { invoked = true }),
+	)
+	assert.ErrorIs(err, context.Canceled)
+	var execute *core.ErrExecute
+	assert.ErrorAs(err, &execute)
+	assert.Equal("Provide(github.com/aegistudio/shaft_test.provideChainB)",
+		execute.Node)
+	assert.False(invoked)
+}
+
+func TestOptional(t *testing.T) {
+	assert := assert.New(t)
+
+	var c *C
+	var d *D
+	var inputs []I
+	invoke := shaft.Optional(shaft.Invoke(func(
+		optionalC *C, optionalD *D, optionalInputs []I,
+	) {
+		c, d, inputs = optionalC, optionalD, optionalInputs
+	}))
+	assert.NoError(shaft.Run(invoke))
+	assert.Nil(c)
+	assert.Nil(d)
+	assert.Empty(inputs)
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.Provide(redundantObjectC),
+		shaft.Provide(func(c *C) *C {
+			events = append(events, "decorate c")
+			return c
+		}),
+		invoke,
+	))
+	assert.NotNil(c)
+	assert.Nil(d)
+	assert.Equal([]string{"provide c", "decorate c"}, events)
+
+	assert.Error(shaft.Run(shaft.Invoke(func(*C) {})))
+}
+
+type database struct {
+	addr string
+}
+
+func TestNamed(t *testing.T) {
+	assert := assert.New(t)
+
+	var primary, replica *database
+	assert.NoError(shaft.Run(
+		shaft.Provide(func() *database {
+			return &database{addr: "primary"}
+		}),
+		shaft.Named("replica", func(primary *database) *database {
+			return &database{addr: primary.addr + "-replica"}
+		}),
+		shaft.Invoke(func(db *database) {
+			primary = db
+		}),
+		shaft.UseNamed("replica", new(*database),
+			shaft.Invoke(func(db *database) {
+				replica = db
+			})),
+	))
+	assert.Equal("primary", primary.addr)
+	assert.Equal("primary-replica", replica.addr)
+
+	assert.EqualError(shaft.Run(shaft.UseNamed(
+		"replica", new(*database), shaft.Invoke(func(*database) {}),
+	)), fmt.Sprintf("node %q dependency error: "+
+		"type replica missing dependency",
+		"Invoke(github.com/aegistudio/shaft_test.TestNamed.func5)"))
+}
+
+func TestDecorateOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.Provide(redundantObjectC),
+		shaft.DecorateOrder(2, func(events *[]string, c *C) *C {
+			*events = append(*events, "decorate c 2")
+			return c
+		}),
+		shaft.Provide(func(events *[]string, c *C) *C {
+			*events = append(*events, "decorate c 0")
+			return c
+		}),
+		shaft.DecorateOrder(1, func(events *[]string, c *C) *C {
+			*events = append(*events, "decorate c 1")
+			return c
+		}),
+		shaft.Invoke(func(*C) {}),
+	))
+	assert.Equal([]string{
+		"provide c",
+		"decorate c 0",
+		"decorate c 1",
+		"decorate c 2",
+	}, events)
+	assert.Panics(func() {
+		shaft.DecorateOrder(1, redundantObjectC)
+	})
+}
+
+func TestRunWithResult(t *testing.T) {
+	assert := assert.New(t)
+
+	result, err := shaft.RunWithResult(
+		shaft.Provide(func(w *shaft.Warnings) *C {
+			w.Warn("fallback to default %s", "c")
+			return &C{}
+		}),
+		shaft.Invoke(func(*C) {}),
+	)
+	assert.NoError(err)
+	assert.Equal([]string{"fallback to default c"}, result.Warnings)
+}
+
+type config struct {
+	name string
+}
+
+func TestProvideValueAndPtr(t *testing.T) {
+	assert := assert.New(t)
+
+	var value config
+	var ptr *config
+	assert.NoError(shaft.Run(
+		shaft.ProvideValueAndPtr(func() config {
+			return config{name: "shaft"}
+		}),
+		shaft.Populate(&value, &ptr),
+	))
+	assert.Equal("shaft", value.name)
+	assert.Equal("shaft", ptr.name)
+}
+
+func TestSupplyConflict(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, line, _ := runtime.Caller(0)
+	err := shaft.Run(
+		shaft.Supply(&C{}),
+		shaft.Module(shaft.Supply(&C{})),
+		shaft.Supply([]I{&A{}}),
+		shaft.Supply([]I{&D{}}),
+	)
+	assert.EqualError(err, fmt.Sprintf(
+		"nodes %q, %q conflict on type *shaft_test.C",
+		fmt.Sprintf("Supply(*shaft_test.C) at run_test.go:%d", line+2),
+		fmt.Sprintf("Supply(*shaft_test.C) at run_test.go:%d", line+3),
+	))
+
+	// The default supply is overridden instead of conflicting.
+	c1, c2 := &C{}, &C{}
+	var c *C
+	assert.NoError(shaft.Run(
+		shaft.Default(shaft.Supply(c1)),
+		shaft.Supply(c2),
+		shaft.Populate(&c),
+	))
+	assert.Same(c2, c)
+}
+
+func TestNoSupplyShadow(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	opts := []shaft.Option{
+		shaft.Supply(&events),
+		shaft.Provide(redundantObjectC),
+		shaft.Invoke(func(*C) {}),
+	}
+	_, _, line, _ := runtime.Caller(0)
+	supplyC := shaft.Supply(&C{})
+	assert.NoError(shaft.Run(append(opts, shaft.NoSupplyShadow())...))
+	assert.NoError(shaft.Run(shaft.NoSupplyShadow(), supplyC,
+		shaft.Supply([]I{&A{}}), shaft.Provide(provideObjectA)))
+	assert.EqualError(shaft.Run(append(opts,
+		shaft.NoSupplyShadow(), supplyC)...), fmt.Sprintf(
+		"type *shaft_test.C supplied by node %q is also provided by node %q",
+		fmt.Sprintf("Supply(*shaft_test.C) at run_test.go:%d", line+1),
+		"Provide(github.com/aegistudio/shaft_test.redundantObjectC)",
+	))
+}
+
+func TestRequireErrorReturns(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.RequireErrorReturns(),
+		shaft.Supply(&events),
+		shaft.Provide(redundantObjectC),
+		shaft.EnableFeature("feature"),
+		shaft.ProvideHealthCheck("check", func() (
+			func(context.Context) error, error,
+		) {
+			return nil, nil
+		}),
+		shaft.Partitioned(func(key string) *C { return &C{} }),
+		shaft.ProvideMapAsGroup(map[string]int{"a": 1},
+			func(key string, value int) I { return &A{} }),
+		shaft.Invoke(func(
+			*C, shaft.Features, []shaft.HealthCheck,
+			*shaft.Partition[string, *C], []I,
+		) {
+		}),
+	))
+	assert.EqualError(shaft.Run(
+		shaft.RequireErrorReturns(),
+		shaft.Supply(&events),
+		shaft.Provide(redundantObjectC),
+		shaft.Provide(provideChainA),
+		shaft.Invoke(func(*C) {}),
+	), "node \"Provide(github.com/aegistudio/shaft_test.provideChainA)\" "+
+		"does not return an error")
+}
+
+func TestOnce(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	moduleC := shaft.Once("c", shaft.Provide(redundantObjectC))
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		moduleC,
+		shaft.Module(moduleC),
+		shaft.Invoke(func(*C) {}),
+	))
+	assert.Equal([]string{"provide c"}, events)
+}
+
+func TestAssignableMatching(t *testing.T) {
+	assert := assert.New(t)
+
+	a := &A{}
+	assert.NoError(shaft.Run(
+		shaft.AssignableMatching(),
+		shaft.Supply(a),
+		shaft.Invoke(func(i I) {
+			assert.Same(a, i)
+		}),
+	))
+	assert.Error(shaft.Run(
+		shaft.Supply(a),
+		shaft.Invoke(func(I) {}),
+	))
+	err := shaft.Run(
+		shaft.AssignableMatching(),
+		shaft.Supply(a),
+		shaft.Supply(&D{}),
+		shaft.Invoke(func(I) {}),
+	)
+	assert.ErrorContains(err, "type shaft_test.I ambigious dependency")
+}
+
+func TestInvokeDependencyOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	opts := []shaft.Option{
+		shaft.Supply(&events),
+		shaft.Provide(func() *chainA { return &chainA{} }),
+		shaft.Provide(func(*chainA) *chainB { return &chainB{} }),
+		shaft.Invoke(func(events *[]string, _ *chainB) {
+			*events = append(*events, "invoke b")
+		}),
+		shaft.Invoke(func(events *[]string, _ *chainA) {
+			*events = append(*events, "invoke a")
+		}),
+	}
+	assert.NoError(shaft.Run(opts...))
+	assert.Equal([]string{"invoke b", "invoke a"}, events)
+
+	events = nil
+	assert.NoError(shaft.Run(append(opts,
+		shaft.InvokeDependencyOrder())...))
+	assert.Equal([]string{"invoke a", "invoke b"}, events)
+}
+
+func failDecorateGroup(inputs []I) ([]I, error) {
+	return nil, errors.New("decorate failed")
+}
+
+func TestDecorateFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	a, d := &A{}, &D{}
+	var group []I
+	result, err := shaft.RunWithResult(
+		shaft.Supply([]I{a}),
+		shaft.Supply([]I{d}),
+		shaft.DecorateFallback(failDecorateGroup),
+		shaft.Populate(&group),
+	)
+	assert.NoError(err)
+	assert.Equal([]I{a, d}, group)
+	assert.Equal([]string{
+		"DecorateFallback(github.com/aegistudio/shaft_test." +
+			"failDecorateGroup) fallback: decorate failed",
+	}, result.Warnings)
+}
+
+func TestPopulateStruct(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	var result struct {
+		C      *C
+		Inputs []I
+		ignore *B
+	}
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.Provide(redundantObjectC),
+		shaft.Supply([]I{&A{}}),
+		shaft.PopulateStruct(&result),
+	))
+	assert.NotNil(result.C)
+	assert.Len(result.Inputs, 1)
+	assert.Nil(result.ignore)
+	assert.Panics(func() {
+		shaft.PopulateStruct(result)
+	})
+}
+
+func TestFillStruct(t *testing.T) {
+	assert := assert.New(t)
+
+	// The preset field is neither consumed nor overwritten, so
+	// it needs no provider.
+	var events []string
+	preset := &C{}
+	result := struct {
+		C      *C
+		Events *[]string
+	}{C: preset}
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.FillStruct(&result),
+	))
+	assert.Same(preset, result.C)
+	assert.Same(&events, result.Events)
+}
+
+func TestExtract(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	var name string
+	var count int
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.Provide(redundantObjectC),
+		shaft.Extract(func(c *C, events *[]string) (string, int, error) {
+			return "extracted", len(*events), nil
+		}, &name, &count),
+	))
+	assert.Equal("extracted", name)
+	assert.Equal(1, count)
+
+	errFailed := errors.New("failed")
+	name = ""
+	assert.ErrorIs(shaft.Run(
+		shaft.Extract(func() (string, error) {
+			return "failed", errFailed
+		}, &name),
+	), errFailed)
+	assert.Empty(name)
+	assert.Panics(func() {
+		shaft.Extract(func() (string, int) { return "", 0 }, &name)
+	})
+	assert.Panics(func() {
+		shaft.Extract(func() int { return 0 }, &name)
+	})
+}
+
+func TestDecorateSupplyWarning(t *testing.T) {
+	assert := assert.New(t)
+
+	_, _, line, _ := runtime.Caller(0)
+	result, err := shaft.RunWithResult(
+		shaft.Supply(&C{}),
+		shaft.Provide(func(c *C) *C { return c }),
+		shaft.Invoke(func(*C) {}),
+	)
+	assert.NoError(err)
+	assert.Equal([]string{fmt.Sprintf(
+		"type *shaft_test.C supplied by node %q is decorated",
+		fmt.Sprintf("Supply(*shaft_test.C) at run_test.go:%d", line+2),
+	)}, result.Warnings)
+
+	result, err = shaft.RunWithResult(
+		shaft.Provide(redundantObjectC),
+		shaft.Supply(&[]string{}),
+		shaft.Provide(func(c *C) *C { return c }),
+		shaft.Invoke(func(*C) {}),
+	)
+	assert.NoError(err)
+	assert.Empty(result.Warnings)
+}
+
+func TestDeprecated(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	opts := []shaft.Option{
+		shaft.Supply(&events),
+		shaft.Deprecated("use Supply(&C{}) instead", redundantObjectC),
+	}
+	result, err := shaft.RunWithResult(opts...)
+	assert.NoError(err)
+	assert.Empty(result.Warnings)
+
+	result, err = shaft.RunWithResult(append(opts,
+		shaft.Invoke(func(*C) {}))...)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"Deprecated(github.com/aegistudio/shaft_test.redundantObjectC) " +
+			"is deprecated: use Supply(&C{}) instead",
+	}, result.Warnings)
+	assert.Equal([]string{"provide c"}, events)
+
+	var warnings []string
+	program, err := shaft.Compile(append(opts,
+		shaft.Invoke(func(_ *C, w *shaft.Warnings) {
+			warnings = w.List()
+		}))...)
+	assert.NoError(err)
+	assert.NoError(program.Run())
+	assert.Equal(result.Warnings, warnings)
+}
+
+func TestNoImplicitDecorate(t *testing.T) {
+	assert := assert.New(t)
+
+	decorate := func(c *C) *C { return c }
+	err := shaft.Run(
+		shaft.NoImplicitDecorate(),
+		shaft.Supply(&C{}),
+		shaft.Provide(decorate),
+		shaft.Invoke(func(*C) {}),
+	)
+	assert.ErrorContains(err, "implicitly decorates type *shaft_test.C")
+	assert.NoError(shaft.Run(
+		shaft.NoImplicitDecorate(),
+		shaft.Supply(&C{}),
+		shaft.Decorate(decorate),
+		shaft.Invoke(func(*C) {}),
+	))
+}
+
+func TestStackOutcome(t *testing.T) {
+	assert := assert.New(t)
+
+	errInvoke := errors.New("invoke failed")
+	stackC := shaft.Stack(func(
+		f func(*C) error, events *[]string,
+	) error {
+		if err := f(&C{}); err != nil {
+			*events = append(*events, "rollback c")
+			return err
+		}
+		*events = append(*events, "commit c")
+		return nil
+	})
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events), stackC,
+		shaft.Invoke(func(*C) error { return nil }),
+	))
+	assert.Equal([]string{"commit c"}, events)
+
+	events = nil
+	assert.ErrorIs(shaft.Run(
+		shaft.Supply(&events), stackC,
+		shaft.Invoke(func(*C) error { return errInvoke }),
+	), errInvoke)
+	assert.Equal([]string{"rollback c"}, events)
+}
+
+func TestStackChain(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.Stack(func(f func(*B) error, c *C, events *[]string) error {
+			*events = append(*events, "enter b")
+			defer func() { *events = append(*events, "leave b") }()
+			return f(&B{})
+		}),
+		shaft.Stack(func(f func(*C) error, events *[]string) error {
+			*events = append(*events, "enter c")
+			defer func() { *events = append(*events, "leave c") }()
+			return f(&C{})
+		}),
+		shaft.Invoke(func(*B, *[]string) {
+			events = append(events, "invoke")
+		}),
+	))
+	assert.Equal([]string{
+		"enter c", "enter b", "invoke", "leave b", "leave c",
+	}, events)
+}
+
+func TestWithUnusedReporter(t *testing.T) {
+	assert := assert.New(t)
+
+	var unused []string
+	var called bool
+	assert.NoError(shaft.Run(
+		shaft.WithUnusedReporter(func(nodes []string) {
+			called = true
+			unused = nodes
+		}),
+		shaft.Provide(func() int { return 1 }),
+		shaft.Provide(func(v int) string { return "unused" }),
+		shaft.Provide(func() float64 { return 2 }),
+		shaft.Invoke(func(v float64) {}),
+	))
+	assert.True(called)
+	assert.Equal([]string{
+		"Provide(github.com/aegistudio/shaft_test.TestWithUnusedReporter.func2)",
+		"Provide(github.com/aegistudio/shaft_test.TestWithUnusedReporter.func3)",
+	}, unused)
+}
+
+func provideDatabase() *database {
+	return &database{addr: "postgres://production"}
+}
+
+func TestReplace(t *testing.T) {
+	assert := assert.New(t)
+
+	var db *database
+	assert.NoError(shaft.Run(
+		shaft.Provide(provideDatabase),
+		shaft.Replace(func() *database {
+			return &database{addr: "sqlite://memory"}
+		}),
+		shaft.Populate(&db),
+	))
+	assert.Equal("sqlite://memory", db.addr)
+
+	err := shaft.Run(
+		shaft.Replace(func() *database { return nil }),
+		shaft.Populate(&db),
+	)
+	assert.ErrorContains(err, "is not provided by other nodes")
+}
+
+func TestRunWithStats(t *testing.T) {
+	assert := assert.New(t)
+
+	stats, err := shaft.RunWithStats(
+		shaft.Provide(provideDatabase),
+		shaft.Decorate(func(db *database) *database {
+			return &database{addr: db.addr + "?pool=1"}
+		}),
+		shaft.Provide(func(db *database) []plugin {
+			return []plugin{plugin(db.addr)}
+		}),
+		shaft.Invoke(func(*database, []plugin) {}),
+		shaft.Invoke(func(*database) {}),
+	)
+	assert.NoError(err)
+	name := "Provide(github.com/aegistudio/shaft_test.provideDatabase)"
+	assert.Equal(1, stats.Executions[name])
+	for name, count := range stats.Executions {
+		assert.Equal(1, count, name)
+	}
+}
+
+func TestPopulateGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	var handlers []handler
+	var single handler
+	assert.NoError(shaft.Run(
+		shaft.Provide(provideHandlerX),
+		shaft.Provide(provideHandlerY),
+		shaft.Provide(func() handler {
+			return handlerFunc(func() string { return "z" })
+		}),
+		shaft.Populate(&handlers, &single),
+	))
+	var names []string
+	for _, h := range handlers {
+		names = append(names, h.handle())
+	}
+	assert.Equal([]string{"x", "y"}, names)
+	assert.Equal("z", single.handle())
+
+	handlers = nil
+	assert.NoError(shaft.Run(shaft.Populate(&handlers)))
+	assert.Empty(handlers)
+}
+
+func TestProvideIf(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enabled := range []bool{true, false} {
+		var db *database
+		var addr string
+		assert.NoError(shaft.Run(
+			shaft.ProvideIf(enabled, provideDatabase),
+			shaft.SupplyIf(enabled, "postgres://replica"),
+			shaft.Optional(shaft.Populate(&db, &addr)),
+		))
+		if enabled {
+			assert.Equal("postgres://production", db.addr)
+			assert.Equal("postgres://replica", addr)
+		} else {
+			assert.Nil(db)
+			assert.Empty(addr)
+		}
+	}
+
+	assert.Error(shaft.Run(
+		shaft.ProvideIf(false, provideDatabase),
+		shaft.Invoke(func(*database) {}),
+	))
+}
+
+func TestProvideTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	var db *database
+	assert.NoError(shaft.Run(
+		shaft.ProvideTimeout(time.Second, provideDatabase),
+		shaft.Populate(&db),
+	))
+	assert.Equal("postgres://production", db.addr)
+
+	release := make(chan struct{})
+	defer close(release)
+	invoked := false
+	err := shaft.Run(
+		shaft.ProvideTimeout(10*time.Millisecond, func() *database {
+			<-release
+			return &database{}
+		}),
+		shaft.Invoke(func(*database) { invoked = true }),
+	)
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	var execErr *core.ErrExecute
+	assert.ErrorAs(err, &execErr)
+	assert.Equal("ProvideTimeout(github.com/aegistudio/shaft_test."+
+		"TestProvideTimeout.func1)", execErr.Node)
+	assert.False(invoked)
+}
+
+func TestNamedModule(t *testing.T) {
+	assert := assert.New(t)
+
+	errProvide := errors.New("provide failed")
+	err := shaft.Run(
+		shaft.NamedModule("auth",
+			shaft.Provide(provideChainA),
+			shaft.NamedModule("token",
+				shaft.Provide(func(*chainA) (*chainB, error) {
+					return nil, errProvide
+				}),
+			),
+		),
+		shaft.NamedModule("main", shaft.Invoke(func(*chainB) {})),
+	)
+	var execErr *core.ErrExecute
+	assert.ErrorAs(err, &execErr)
+	assert.Equal("auth: token: Provide(github.com/aegistudio/shaft_test."+
+		"TestNamedModule.func1)", execErr.Node)
+
+	plan, err := shaft.Plan(
+		shaft.NamedModule("auth", shaft.Provide(provideChainA)),
+		shaft.Provide(provideChainB),
+		shaft.NamedModule("main", shaft.Invoke(func(*chainB) {})),
+	)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"auth: Provide(github.com/aegistudio/shaft_test.provideChainA)",
+		"Provide(github.com/aegistudio/shaft_test.provideChainB)",
+		"main: Invoke(github.com/aegistudio/shaft_test.TestNamedModule.func3)",
+	}, plan)
+
+	err = shaft.Run(
+		shaft.NamedModule("auth", shaft.Provide(provideChainB)),
+		shaft.Invoke(func(*chainB) {}),
+	)
+	assert.ErrorContains(err, `node "auth: Provide(`+
+		`github.com/aegistudio/shaft_test.provideChainB)" dependency error`)
+}
+
+func TestStackGroup(t *testing.T) {
+	for _, concurrency := range []int{1, 4} {
+		assert := assert.New(t)
+
+		var events []string
+		var plugins []plugin
+		assert.NoError(shaft.Run(
+			shaft.WithConcurrency(concurrency),
+			shaft.Supply(&events),
+			shaft.Provide(func(*B, *[]string) []plugin {
+				events = append(events, "provide inner")
+				return []plugin{"inner"}
+			}),
+			shaft.Stack(func(
+				f func(*B, []plugin) error, events *[]string,
+			) error {
+				*events = append(*events, "enter stack")
+				defer func() { *events = append(*events, "exit stack") }()
+				return f(&B{}, []plugin{"stack"})
+			}),
+			shaft.Provide(func() []plugin {
+				return []plugin{"outer"}
+			}),
+			shaft.Invoke(func(members []plugin, events *[]string) {
+				plugins = members
+				*events = append(*events, "invoke")
+			}),
+		))
+
+		// The members are collected in the order of provision,
+		// regardless of whether they are provided inside the
+		// scope of the stacked function or not.
+		assert.Equal([]plugin{"inner", "stack", "outer"}, plugins)
+		assert.Equal([]string{
+			"enter stack", "provide inner", "invoke", "exit stack",
+		}, events)
+	}
+}
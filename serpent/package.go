@@ -6,6 +6,9 @@ package serpent
 import (
 	"context"
 	"errors"
+	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/aegistudio/shaft"
 	"github.com/aegistudio/shaft/core"
@@ -16,6 +19,12 @@ type commandOptionKey struct{}
 
 type commandOptionValue struct {
 	options []core.Option
+
+	// base is the base options collected from the commands, and
+	// collect is set when only the base options are collected,
+	// see also WithBaseOptions.
+	base    []core.Option
+	collect bool
 }
 
 // retrieveOptionValue attempts to retrieve option value from the
@@ -34,23 +43,180 @@ func retrieveOptionValue(cmd *cobra.Command) (*commandOptionValue, error) {
 	return value, nil
 }
 
+// baseOptionsAnnotation marks the commands whose persistent
+// pre-run function has been wrapped by WithBaseOptions.
+const baseOptionsAnnotation = "serpent.base-options"
+
+// WithBaseOptions attaches the base options to the command,
+// which are visible to the command and all its subcommands
+// executed, no matter whether the command is executed with
+// Execute or not, and whether the commands in between are
+// managed by serpent or not. The command is returned for
+// chaining, and the options are appended if attached again.
+//
+// The options are applied in the following order when a command
+// is run: the base options from the root to the command, the
+// options passed to Execute, then the options added by the
+// executors of the ancestors from the nearest one, and finally
+// the executor of the command. So the earlier ones win in Once,
+// and the group members are collected in this order.
+//
+// The options are kept by wrapping the persistent pre-run
+// function of the command, which is still executed before
+// the options are added, so it must be set before attaching.
+func WithBaseOptions(cmd *cobra.Command, options ...core.Option) *cobra.Command {
+	runE, run := cmd.PersistentPreRunE, cmd.PersistentPreRun
+	_, wrapped := cmd.Annotations[baseOptionsAnnotation]
+	cmd.PersistentPreRunE = func(c *cobra.Command, args []string) error {
+		value, _ := retrieveOptionValue(c)
+		collect := value != nil && value.collect
+		if runE != nil && (wrapped || !collect) {
+			if err := runE(c, args); err != nil {
+				return err
+			}
+		} else if run != nil && !collect {
+			run(c, args)
+		}
+		if value != nil {
+			value.base = append(value.base, options...)
+		}
+		return nil
+	}
+	cmd.PersistentPreRun = nil
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[baseOptionsAnnotation] = ""
+	return cmd
+}
+
+// collectBaseOptions collects the base options attached to the
+// command and its ancestors, from the root to the command, when
+// it is not executed by serpent, and so the persistent pre-run
+// functions of the ancestors have not been executed.
+func collectBaseOptions(cmd *cobra.Command) ([]core.Option, error) {
+	var path []*cobra.Command
+	for c := cmd; c != nil; c = c.Parent() {
+		path = append([]*cobra.Command{c}, path...)
+	}
+	value := &commandOptionValue{collect: true}
+	collector := &cobra.Command{}
+	collector.SetContext(context.WithValue(
+		context.Background(), commandOptionKey{}, value))
+	for _, c := range path {
+		if _, ok := c.Annotations[baseOptionsAnnotation]; !ok {
+			continue
+		}
+		if err := c.PersistentPreRunE(collector, nil); err != nil {
+			return nil, err
+		}
+	}
+	return value.base, nil
+}
+
 // ExecuteContext sets up the command context and invoke the
 // specified function.
+//
+// Unlike cobra, which only executes the nearest persistent
+// pre-run function, the persistent pre-run functions of the
+// executed command and all of its ancestors are executed
+// exactly once, in the order from the root to the executed
+// command. So the commands must not be executed concurrently.
 func ExecuteContext(
 	ctx context.Context, cmd *cobra.Command, options ...core.Option,
 ) error {
 	ctx = context.WithValue(ctx, commandOptionKey{}, &commandOptionValue{
 		options: options,
 	})
+	defer chainPersistentPreRun(cmd.Root())()
+	defer restoreContext(cmd.Root())()
 	return cmd.ExecuteContext(ctx)
 }
 
+// restoreContext returns the function for restoring the context
+// of each command in the tree, since cobra only propagates the
+// context to the subcommands whose context is nil, and the
+// options of this execution must not leak into the next one.
+func restoreContext(root *cobra.Command) func() {
+	var restores []func()
+	var walk func(*cobra.Command)
+	walk = func(c *cobra.Command) {
+		ctx := c.Context()
+		restores = append(restores, func() {
+			c.SetContext(ctx)
+		})
+		for _, child := range c.Commands() {
+			walk(child)
+		}
+	}
+	walk(root)
+	return func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
+
+// chainPersistentPreRun replaces the persistent pre-run function
+// of each command in the tree with the chain of those defined
+// from the root to the command, and returns the function for
+// restoring the original ones.
+func chainPersistentPreRun(root *cobra.Command) func() {
+	type hook func(*cobra.Command, []string) error
+	var restores []func()
+	var walk func(*cobra.Command, []hook)
+	walk = func(c *cobra.Command, hooks []hook) {
+		runE, run := c.PersistentPreRunE, c.PersistentPreRun
+		if runE != nil {
+			hooks = append(hooks[:len(hooks):len(hooks)], runE)
+		} else if run != nil {
+			hooks = append(hooks[:len(hooks):len(hooks)],
+				func(cmd *cobra.Command, args []string) error {
+					run(cmd, args)
+					return nil
+				})
+		}
+		if len(hooks) > 0 {
+			chain := hooks
+			c.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+				for _, f := range chain {
+					if err := f(cmd, args); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			restores = append(restores, func() {
+				c.PersistentPreRunE, c.PersistentPreRun = runE, run
+			})
+		}
+		for _, child := range c.Commands() {
+			walk(child, hooks)
+		}
+	}
+	walk(root, nil)
+	return func() {
+		for _, restore := range restores {
+			restore()
+		}
+	}
+}
+
 // Execute sets up the command context and invoke the specified
 // functions.
 func Execute(cmd *cobra.Command, options ...core.Option) error {
 	return ExecuteContext(context.Background(), cmd, options...)
 }
 
+// ExecuteResult is like Execute, but also populates the result
+// from the dependency injection when the command is run, so that
+// the values produced inside can be returned to the caller.
+func ExecuteResult(
+	cmd *cobra.Command, result interface{}, options ...core.Option,
+) error {
+	return Execute(cmd, core.Module(options...), shaft.Populate(result))
+}
+
 // AddOption attempts add options to the current command.
 func AddOption(cmd *cobra.Command, options ...core.Option) error {
 	value, err := retrieveOptionValue(cmd)
@@ -69,7 +235,8 @@ func AddOption(cmd *cobra.Command, options ...core.Option) error {
 // specified in the executor to subcommands under its directory.
 // Actually the execution is not based on the cobra's, and we
 // require the user to ensure at least the path from the executed
-// command to the root command is managed by the serpent.
+// command to the root command is managed by the serpent, unless
+// the options are attached by WithBaseOptions instead.
 //
 // When RunE is attached, the command collects all previously
 // provided options up to this node and execute them.
@@ -84,6 +251,92 @@ type CommandContext context.Context
 // CommandArgs is the arguments passed in the command.
 type CommandArgs []string
 
+// CommandPath is the full path of the executed command, e.g.
+// "app server start", see also cobra.Command.CommandPath.
+type CommandPath string
+
+// WithTimeout sets an overall deadline of the command, which
+// becomes the deadline of the context of the run, so that the
+// remaining nodes are not executed after it, and the providers
+// and invokes observe it through CommandContext or the
+// shaft.Context. The command returns the error of the context
+// if the deadline is exceeded before completion.
+func WithTimeout(d time.Duration) core.Option {
+	return core.WithContext(func(
+		ctx context.Context,
+	) (context.Context, context.CancelFunc) {
+		return context.WithTimeout(ctx, d)
+	})
+}
+
+// commandContextOp is the display name of the node supplying
+// the CommandContext.
+type commandContextOp struct{}
+
+func (commandContextOp) String() string {
+	return "Supply(serpent.CommandContext)"
+}
+
+// flagOp is the display name of the node providing a flag.
+type flagOp struct {
+	name string
+	typ  reflect.Type
+}
+
+func (o flagOp) String() string {
+	return fmt.Sprintf("ProvideFlag(%s[--%s])", o.typ, o.name)
+}
+
+// ProvideFlag provides the value of the named flag of the
+// executed command, including the persistent flags of its
+// ancestors, as a dependency of the type of target, which is
+// specified as a pointer to it, e.g. new(Port). The flag is
+// read when the command is run, so the injected functions can
+// simply declare a parameter of the type for the flag value.
+//
+// The value of the flag must be convertible to the type, e.g.
+// the value of an IntVar flag to "type Port int". A slice type
+// is provided as a group just like shaft.Provide, e.g. the
+// value of a StringSlice flag as "type Tags []string".
+func ProvideFlag(name string, target interface{}) core.Option {
+	typ := reflect.TypeOf(target)
+	if typ == nil || typ.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("invalid non-ptr %T flag target", target))
+	}
+	typ = typ.Elem()
+	return core.Provide(func(in []reflect.Value) ([]reflect.Value, error) {
+		cmd := in[0].Interface().(CommandObject)
+		flag := (*cobra.Command)(cmd).Flag(name)
+		if flag == nil {
+			return nil, fmt.Errorf("flag %q is not defined", name)
+		}
+		value := reflect.ValueOf(flag.Value)
+		if slice, ok := flag.Value.(sliceValue); ok &&
+			typ.Kind() == reflect.Slice {
+			value = reflect.ValueOf(slice.GetSlice())
+		} else if value.Kind() == reflect.Ptr {
+			value = value.Elem()
+		}
+		// XXX: integers are convertible to strings as runes,
+		// which is never what we want for the flag values.
+		if !value.Type().ConvertibleTo(typ) || (typ.Kind() ==
+			reflect.String && value.Kind() != reflect.String) {
+			return nil, fmt.Errorf(
+				"flag %q of type %s cannot be converted to %s",
+				name, flag.Value.Type(), typ)
+		}
+		return []reflect.Value{value.Convert(typ)}, nil
+	}, []core.Spec{
+		shaft.SpecOf(reflect.TypeOf(CommandObject(nil))),
+	}, []core.Spec{shaft.SpecOf(typ)}, flagOp{name: name, typ: typ})
+}
+
+// sliceValue is the flag value of a slice, e.g. the one of
+// StringSlice, see also pflag.SliceValue.
+type sliceValue interface {
+	GetSlice() []string
+}
+
 func (e Executor) PreRunE(cmd *cobra.Command, args []string) error {
 	return AddOption(cmd, core.Option(e))
 }
@@ -91,9 +344,28 @@ func (e Executor) PreRunE(cmd *cobra.Command, args []string) error {
 func (e Executor) RunE(cmd *cobra.Command, args []string) error {
 	// XXX: see also Command.execute in cobra/command.go.
 	//
-	// Only the nearest PersistentPreRun function will be executed, but
-	// we will want So we will simply forward the invoke a further step
-	// before executing logics here.
+	// The PreRun functions of the ancestors are never executed by
+	// cobra, but the executors of the ancestors are attached to
+	// them, so we will simply forward the invoke a further step
+	// before executing logics here. The persistent ones have been
+	// chained by ExecuteContext instead.
+	if _, err := retrieveOptionValue(cmd); err != nil {
+		base, err := collectBaseOptions(cmd)
+		if err != nil {
+			return err
+		}
+		if len(base) > 0 {
+			// The command is not executed by serpent, but there're
+			// base options, so we set up the context for this run.
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			cmd.SetContext(context.WithValue(ctx,
+				commandOptionKey{}, &commandOptionValue{base: base}))
+			defer cmd.SetContext(ctx)
+		}
+	}
 	for p := cmd.Parent(); p != nil; p = p.Parent() {
 		if f := p.PreRunE; f != nil {
 			if err := f(cmd, args); err != nil {
@@ -107,10 +379,28 @@ func (e Executor) RunE(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	return core.Run(
+	return shaft.RunContext(cmd.Context(),
 		shaft.Supply(CommandObject(cmd), (*CommandObject)(nil)),
 		shaft.Supply(CommandArgs(args), (*CommandArgs)(nil)),
-		shaft.Supply(CommandContext(cmd.Context()), (*CommandContext)(nil)),
-		core.Module(value.options...), core.Option(e),
+		shaft.Supply(CommandPath(cmd.CommandPath()), (*CommandPath)(nil)),
+		core.SupplyContext(func(ctx context.Context) reflect.Value {
+			return reflect.ValueOf(CommandContext(ctx))
+		}, core.Spec{
+			Type: reflect.TypeOf((*CommandContext)(nil)).Elem(),
+		}, commandContextOp{}),
+		core.Module(value.base...), core.Module(value.options...),
+		core.Option(e),
 	)
 }
+
+// Command attaches the function to the command as its RunE,
+// which is invoked with its arguments injected just like
+// shaft.Invoke, so that the handlers of the command line are
+// just functions whose dependencies are injected.
+//
+// It is a shorthand for setting Executor(shaft.Invoke(fn)).RunE,
+// and the command is returned for chaining.
+func Command(cmd *cobra.Command, fn interface{}) *cobra.Command {
+	cmd.RunE = Executor(shaft.Invoke(fn)).RunE
+	return cmd
+}
@@ -0,0 +1,366 @@
+package serpent_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+	"github.com/aegistudio/shaft/core"
+	"github.com/aegistudio/shaft/serpent"
+)
+
+type exitStatus int
+
+func TestExecuteResult(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd := &cobra.Command{
+		Use: "app",
+		RunE: serpent.Executor(shaft.Provide(
+			func(args serpent.CommandArgs) exitStatus {
+				return exitStatus(len(args))
+			},
+		)).RunE,
+	}
+	cmd.SetArgs([]string{"a", "b", "c"})
+	var status exitStatus
+	assert.NoError(serpent.ExecuteResult(cmd, &status))
+	assert.Equal(exitStatus(3), status)
+}
+
+func TestCommandPath(t *testing.T) {
+	assert := assert.New(t)
+
+	var path serpent.CommandPath
+	root := &cobra.Command{Use: "app"}
+	server := &cobra.Command{Use: "server"}
+	start := &cobra.Command{
+		Use: "start",
+		RunE: serpent.Executor(shaft.Invoke(
+			func(p serpent.CommandPath) {
+				path = p
+			},
+		)).RunE,
+	}
+	root.AddCommand(server)
+	server.AddCommand(start)
+	root.SetArgs([]string{"server", "start"})
+	assert.NoError(serpent.Execute(root))
+	assert.Equal(serpent.CommandPath("app server start"), path)
+}
+
+type listenPort int
+
+func parseListenPort(args serpent.CommandArgs) (listenPort, error) {
+	if len(args) != 1 {
+		return 0, errInvalidArgs
+	}
+	port, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, err
+	}
+	return listenPort(port), nil
+}
+
+var errInvalidArgs = errors.New("expect exactly one port")
+
+func TestCommandArgsError(t *testing.T) {
+	assert := assert.New(t)
+
+	var port listenPort
+	cmd := &cobra.Command{
+		Use:           "app",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: serpent.Executor(shaft.Module(
+			shaft.Provide(parseListenPort),
+			shaft.Populate(&port),
+		)).RunE,
+	}
+	cmd.SetArgs([]string{"8080"})
+	assert.NoError(serpent.Execute(cmd))
+	assert.Equal(listenPort(8080), port)
+
+	cmd.SetArgs([]string{"8080", "8081"})
+	err := serpent.Execute(cmd)
+	assert.ErrorIs(err, errInvalidArgs)
+	var execErr *core.ErrExecute
+	assert.ErrorAs(err, &execErr)
+	assert.Equal(
+		"Provide(github.com/aegistudio/shaft/serpent_test.parseListenPort)",
+		execErr.Node)
+}
+
+func TestWithTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd := &cobra.Command{
+		Use:           "app",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: serpent.Executor(shaft.Invoke(
+			func(ctx serpent.CommandContext) error {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(time.Second):
+					return nil
+				}
+			},
+		)).RunE,
+	}
+	cmd.SetArgs(nil)
+	start := time.Now()
+	err := serpent.Execute(cmd, serpent.WithTimeout(10*time.Millisecond))
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.Less(time.Since(start), time.Second)
+
+	// Nothing is decorated, so it is allowed even if the
+	// implicit decorators are rejected.
+	cmd.SetArgs(nil)
+	err = serpent.Execute(cmd, shaft.NoImplicitDecorate(),
+		serpent.WithTimeout(10*time.Millisecond))
+	assert.ErrorIs(err, context.DeadlineExceeded)
+
+	// The deadline is exceeded even if the CommandContext is
+	// never consumed, and the remaining nodes are not executed.
+	invoked := false
+	cmd = &cobra.Command{
+		Use:           "app",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: serpent.Executor(shaft.Module(
+			shaft.Invoke(func() {
+				time.Sleep(100 * time.Millisecond)
+			}),
+			shaft.Invoke(func(shaft.Context) {
+				invoked = true
+			}),
+		)).RunE,
+	}
+	cmd.SetArgs(nil)
+	err = serpent.Execute(cmd, serpent.WithTimeout(10*time.Millisecond))
+	assert.ErrorIs(err, context.DeadlineExceeded)
+	assert.False(invoked)
+
+	cmd.SetArgs(nil)
+	err = serpent.Execute(cmd, serpent.WithTimeout(time.Second))
+	assert.NoError(err)
+	assert.True(invoked)
+}
+
+func TestExecuteContext(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	invoked := false
+	cmd := &cobra.Command{
+		Use:           "app",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: serpent.Executor(shaft.Invoke(func() {
+			invoked = true
+		})).RunE,
+	}
+	cmd.SetArgs(nil)
+	assert.ErrorIs(serpent.ExecuteContext(ctx, cmd), context.Canceled)
+	assert.False(invoked)
+}
+
+func TestCommand(t *testing.T) {
+	assert := assert.New(t)
+
+	var port listenPort
+	var path serpent.CommandPath
+	root := &cobra.Command{
+		Use:     "app",
+		PreRunE: serpent.Executor(shaft.Provide(parseListenPort)).PreRunE,
+	}
+	serve := serpent.Command(&cobra.Command{Use: "serve"},
+		func(p listenPort, cmdPath serpent.CommandPath) {
+			port, path = p, cmdPath
+		})
+	root.AddCommand(serve)
+	root.SetArgs([]string{"serve", "8080"})
+	assert.NoError(serpent.Execute(root))
+	assert.Equal(listenPort(8080), port)
+	assert.Equal(serpent.CommandPath("app serve"), path)
+}
+
+type serverName string
+
+type serverTags []string
+
+func TestProvideFlag(t *testing.T) {
+	assert := assert.New(t)
+
+	var port listenPort
+	var name serverName
+	var timeout time.Duration
+	root := &cobra.Command{Use: "app"}
+	root.PersistentFlags().Duration("timeout", time.Second, "")
+	start := &cobra.Command{
+		Use: "start",
+		RunE: serpent.Executor(core.Module(
+			serpent.ProvideFlag("port", new(listenPort)),
+			serpent.ProvideFlag("name", new(serverName)),
+			serpent.ProvideFlag("timeout", new(time.Duration)),
+			shaft.Invoke(func(
+				p listenPort, n serverName, d time.Duration,
+			) {
+				port, name, timeout = p, n, d
+			}),
+		)).RunE,
+	}
+	start.Flags().Int("port", 80, "")
+	start.Flags().String("name", "default", "")
+	root.AddCommand(start)
+	root.SetArgs([]string{"start", "--port", "8080", "--timeout", "3s"})
+	assert.NoError(serpent.Execute(root))
+	assert.Equal(listenPort(8080), port)
+	assert.Equal(serverName("default"), name)
+	assert.Equal(3*time.Second, timeout)
+
+	cmd := &cobra.Command{
+		Use:           "app",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: serpent.Executor(core.Module(
+			serpent.ProvideFlag("port", new(serverName)),
+			shaft.Invoke(func(serverName) {}),
+		)).RunE,
+	}
+	cmd.Flags().Int("port", 80, "")
+	cmd.SetArgs(nil)
+	assert.ErrorContains(serpent.Execute(cmd),
+		`flag "port" of type int cannot be converted to serpent_test.serverName`)
+
+	// The slice types are groups, just like shaft.Provide.
+	var tags serverTags
+	cmd = &cobra.Command{
+		Use: "app",
+		RunE: serpent.Executor(core.Module(
+			serpent.ProvideFlag("tag", new(serverTags)),
+			shaft.Supply(serverTags{"default"}),
+			shaft.Invoke(func(t serverTags) {
+				tags = t
+			}),
+		)).RunE,
+	}
+	cmd.Flags().StringSlice("tag", nil, "")
+	cmd.SetArgs([]string{"--tag", "a,b"})
+	assert.NoError(serpent.Execute(cmd))
+	assert.ElementsMatch(serverTags{"a", "b", "default"}, tags)
+}
+
+func TestPersistentPreRun(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	root := &cobra.Command{
+		Use: "app",
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			events = append(events, "root")
+			return nil
+		},
+	}
+	server := &cobra.Command{
+		Use: "server",
+		PersistentPreRun: func(*cobra.Command, []string) {
+			events = append(events, "server")
+		},
+		PreRunE: serpent.Executor(shaft.Provide(func() listenPort {
+			return 8080
+		})).PreRunE,
+	}
+	start := &cobra.Command{
+		Use: "start",
+		PersistentPreRunE: func(*cobra.Command, []string) error {
+			events = append(events, "start")
+			return nil
+		},
+		RunE: serpent.Executor(shaft.Invoke(func(port listenPort) {
+			events = append(events, "run "+strconv.Itoa(int(port)))
+		})).RunE,
+	}
+	root.AddCommand(server)
+	server.AddCommand(start)
+	root.SetArgs([]string{"server", "start"})
+	assert.NoError(serpent.Execute(root))
+	assert.Equal([]string{"root", "server", "start", "run 8080"}, events)
+
+	// The original functions are restored after execution.
+	assert.Nil(server.PersistentPreRunE)
+	assert.NotNil(server.PersistentPreRun)
+	events = nil
+	assert.NoError(root.PersistentPreRunE(root, nil))
+	assert.Equal([]string{"root"}, events)
+
+	errPreRun := errors.New("pre-run failed")
+	events = nil
+	root.PersistentPreRunE = func(*cobra.Command, []string) error {
+		return errPreRun
+	}
+	root.SilenceUsage, root.SilenceErrors = true, true
+	root.SetArgs([]string{"server", "start"})
+	assert.ErrorIs(serpent.Execute(root), errPreRun)
+	assert.Empty(events)
+}
+
+func TestWithBaseOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	record := func(event string) core.Option {
+		return shaft.Supply([]string{event})
+	}
+	root := serpent.WithBaseOptions(&cobra.Command{Use: "app"},
+		record("base root"))
+	server := &cobra.Command{
+		Use: "server",
+		PreRunE: serpent.Executor(
+			record("executor server")).PreRunE,
+	}
+	hooks := 0
+	start := serpent.WithBaseOptions(&cobra.Command{
+		Use: "start",
+		PersistentPreRun: func(*cobra.Command, []string) {
+			hooks++
+		},
+		RunE: serpent.Executor(core.Module(
+			record("executor start"),
+			shaft.Invoke(func(members []string) {
+				events = members
+			}),
+		)).RunE,
+	}, record("base start"))
+	serpent.WithBaseOptions(start, record("base start again"))
+	root.AddCommand(server)
+	server.AddCommand(start)
+
+	root.SetArgs([]string{"server", "start"})
+	assert.NoError(serpent.Execute(root, record("execute")))
+	assert.Equal([]string{
+		"base root", "base start", "base start again", "execute",
+		"executor server", "executor start",
+	}, events)
+	assert.Equal(1, hooks)
+
+	// The base options are visible even if the command is not
+	// executed by serpent.
+	events = nil
+	root.SetArgs([]string{"server", "start"})
+	assert.NoError(root.Execute())
+	assert.Equal([]string{
+		"base root", "base start", "base start again",
+		"executor server", "executor start",
+	}, events)
+	assert.Equal(2, hooks)
+}
@@ -0,0 +1,113 @@
+// Package shafttest provides the helpers for testing the
+// applications built with the shaft framework.
+package shafttest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aegistudio/shaft"
+)
+
+// AssertDeterministicPlan builds the options returned by the
+// function twice, and asserts the execution plans generated
+// from them are identical, which catches the nondeterminism
+// while generating the execution plan.
+func AssertDeterministicPlan(t testing.TB, opts func() []shaft.Option) bool {
+	t.Helper()
+	first, err := shaft.Plan(opts()...)
+	if err != nil {
+		t.Errorf("cannot build execution plan: %v", err)
+		return false
+	}
+	second, err := shaft.Plan(opts()...)
+	if err != nil {
+		t.Errorf("cannot build execution plan: %v", err)
+		return false
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("execution plan is not deterministic: %v != %v",
+			first, second)
+		return false
+	}
+	return true
+}
+
+// intervalObserver records the execution intervals of nodes.
+type intervalObserver struct {
+	mu     sync.Mutex
+	start  map[string]time.Time
+	finish map[string]time.Time
+}
+
+func (o *intervalObserver) OnNodeStart(name string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.start[name] = time.Now()
+}
+
+func (o *intervalObserver) OnNodeFinish(
+	name string, err error, d time.Duration,
+) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.finish[name] = time.Now()
+}
+
+// AssertParallel runs the options, and asserts the execution
+// of the nodes providing typeA and typeB overlapped in time,
+// which verifies they are executed concurrently, e.g. with
+// shaft.WithConcurrency specified.
+//
+// The options must consume both of the types so that their
+// providers are executed, and each type must be provided by
+// exactly one node.
+func AssertParallel(
+	t testing.TB, opts []shaft.Option, typeA, typeB reflect.Type,
+) bool {
+	t.Helper()
+	infos, err := shaft.ProvidedBy(opts...)
+	if err != nil {
+		t.Errorf("cannot inspect providers: %v", err)
+		return false
+	}
+	var names [2][]string
+	for _, info := range infos {
+		for i, typ := range []reflect.Type{typeA, typeB} {
+			if info.Spec.Type == typ {
+				names[i] = append(names[i], info.Node)
+			}
+		}
+	}
+	for i, typ := range []reflect.Type{typeA, typeB} {
+		if len(names[i]) != 1 {
+			t.Errorf("type %s must be provided by exactly one node: %v",
+				typ, names[i])
+			return false
+		}
+	}
+	observer := &intervalObserver{
+		start:  make(map[string]time.Time),
+		finish: make(map[string]time.Time),
+	}
+	if err := shaft.Run(append(append([]shaft.Option(nil),
+		opts...), shaft.WithObserver(observer))...); err != nil {
+		t.Errorf("cannot run options: %v", err)
+		return false
+	}
+	a, b := names[0][0], names[1][0]
+	for _, name := range []string{a, b} {
+		if _, ok := observer.finish[name]; !ok {
+			t.Errorf("node %q is not executed", name)
+			return false
+		}
+	}
+	if !observer.start[a].Before(observer.finish[b]) ||
+		!observer.start[b].Before(observer.finish[a]) {
+		t.Errorf("nodes %q and %q are not executed in parallel", a, b)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package shafttest_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+	"github.com/aegistudio/shaft/shafttest"
+)
+
+type handler interface{}
+
+type server struct{}
+
+type recordTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordTB) Helper() {}
+
+func (r *recordTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertDeterministicPlan(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := []shaft.Option{
+		shaft.Provide(func() []handler { return []handler{1} }),
+		shaft.Provide(func() *server { return &server{} }),
+		shaft.Provide(func() []handler { return []handler{2} }),
+		shaft.Invoke(func([]handler, *server) {}),
+	}
+	assert.True(shafttest.AssertDeterministicPlan(t,
+		func() []shaft.Option { return opts }))
+
+	// Reverse the options on every build to simulate the
+	// nondeterminism while generating the execution plan.
+	tb := &recordTB{TB: t}
+	assert.False(shafttest.AssertDeterministicPlan(tb,
+		func() []shaft.Option {
+			for i, j := 0, len(opts)-2; i < j; i, j = i+1, j-1 {
+				opts[i], opts[j] = opts[j], opts[i]
+			}
+			return opts
+		}))
+	assert.Len(tb.errors, 1)
+}
+
+type (
+	database struct{}
+	cache    struct{}
+)
+
+func TestAssertParallel(t *testing.T) {
+	assert := assert.New(t)
+
+	opts := []shaft.Option{
+		shaft.Provide(func() *database {
+			time.Sleep(20 * time.Millisecond)
+			return &database{}
+		}),
+		shaft.Provide(func() *cache {
+			time.Sleep(20 * time.Millisecond)
+			return &cache{}
+		}),
+		shaft.Invoke(func(*database, *cache) {}),
+	}
+	typeDatabase := reflect.TypeOf(&database{})
+	typeCache := reflect.TypeOf(&cache{})
+	assert.True(shafttest.AssertParallel(t,
+		append([]shaft.Option{shaft.WithConcurrency(2)}, opts...),
+		typeDatabase, typeCache))
+
+	tb := &recordTB{TB: t}
+	assert.False(shafttest.AssertParallel(tb, opts, typeDatabase, typeCache))
+	assert.Len(tb.errors, 1)
+	assert.Contains(tb.errors[0], "are not executed in parallel")
+}
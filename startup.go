@@ -0,0 +1,20 @@
+package shaft
+
+import (
+	"time"
+)
+
+// StartupDuration is the time elapsed since Run is called,
+// including building the execution plan, which is useful for
+// reporting the startup time of the application.
+//
+// The duration is measured right before the first node which
+// consumes it is executed, so it should be consumed by the
+// consumers executed at last, e.g. those invoked by AfterAll.
+type StartupDuration time.Duration
+
+func provideStartupDuration(start time.Time) Option {
+	return convertProvider(func() StartupDuration {
+		return StartupDuration(time.Since(start))
+	}).builtin().option(opProvide)
+}
@@ -0,0 +1,31 @@
+package shaft_test
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/aegistudio/shaft"
+)
+
+func TestProvideOnMainThread(t *testing.T) {
+	assert := assert.New(t)
+
+	var events []string
+	assert.NoError(shaft.Run(
+		shaft.Supply(&events),
+		shaft.ProvideOnMainThread(func(events *[]string) *C {
+			tid := syscall.Gettid()
+			for i := 0; i < 1000; i++ {
+				runtime.Gosched()
+				assert.Equal(tid, syscall.Gettid())
+			}
+			*events = append(*events, "provide c")
+			return &C{}
+		}),
+		shaft.Invoke(func(*C) {}),
+	))
+	assert.Equal([]string{"provide c"}, events)
+}